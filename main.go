@@ -2,15 +2,27 @@ package main
 
 import (
 	"log"
+	"user-auth-profile-service/src/controllers"
 	"user-auth-profile-service/src/routes"
+	"user-auth-profile-service/src/utils"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 )
 
 func main() {
+	if err := utils.InitSigningKeys(); err != nil {
+		log.Fatalf("Failed to load JWT signing keys: %v", err)
+	}
 
 	app := fiber.New()
+	app.Use(requestid.New())
 	routes.UserRoute(app)
 	routes.AuthRoute(app)
+	routes.WellKnownRoute(app)
+	routes.OIDCRoute(app)
+	routes.AdminRoute(app)
+
+	controllers.StartAccountPurgeJob()
 
 	if err := app.Listen(":6400"); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
@@ -20,6 +20,13 @@ func SetupUserIndexes(collection *mongo.Collection) error {
 			Keys:    bson.D{{Key: "username", Value: 1}},
 			Options: options.Index().SetUnique(true),
 		},
+		{
+			// An account has at most one profile
+			Keys: bson.D{{Key: "authId", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"authId": bson.M{"$exists": true},
+			}),
+		},
 	}
 
 	// Create all indexes
@@ -32,10 +39,389 @@ func SetupUserIndexes(collection *mongo.Collection) error {
 	return nil
 }
 
+func SetupAuthIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// A passkey can only belong to one account
+			Keys: bson.D{{Key: "webauthnCredentials.credentialId", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"webauthnCredentials.credentialId": bson.M{"$exists": true},
+			}),
+		},
+		{
+			// Lets the purge job find accounts due for deletion
+			Keys:    bson.D{{Key: "deleteAfter", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			// An external account can only be linked once
+			Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"identities.subject": bson.M{"$exists": true},
+			}),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create auth indexes: %w", err)
+	}
+
+	log.Println("✅ All auth indexes created successfully")
+	return nil
+}
+
+func SetupWebAuthnSessionIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create webauthn session indexes: %w", err)
+	}
+
+	log.Println("✅ All webauthn session indexes created successfully")
+	return nil
+}
+
+func SetupOAuthStateIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create oauth state indexes: %w", err)
+	}
+
+	log.Println("✅ All oauth state indexes created successfully")
+	return nil
+}
+
+func SetupOAuthClientIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "clientId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create oauth client indexes: %w", err)
+	}
+
+	log.Println("✅ All oauth client indexes created successfully")
+	return nil
+}
+
+func SetupAuthorizationCodeIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create authorization code indexes: %w", err)
+	}
+
+	log.Println("✅ All authorization code indexes created successfully")
+	return nil
+}
+
+func SetupLoginAttemptIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			// Old failures are forgotten once the failure window has passed
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create login attempt indexes: %w", err)
+	}
+
+	log.Println("✅ All login attempt indexes created successfully")
+	return nil
+}
+
+func SetupPasswordResetIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			// Only one reset link per account may be usable at a time
+			Keys: bson.D{{Key: "authId", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"active": true}),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create password reset indexes: %w", err)
+	}
+
+	log.Println("✅ All password reset indexes created successfully")
+	return nil
+}
+
+func SetupEmailChangeIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			// Only one change per account may be waiting for confirmation
+			Keys: bson.D{{Key: "authId", Value: 1}},
+			Options: options.Index().
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": "pending"}),
+		},
+		{
+			Keys:    bson.D{{Key: "confirmTokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "revertTokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create email change indexes: %w", err)
+	}
+
+	log.Println("✅ All email change indexes created successfully")
+	return nil
+}
+
+func SetupLoginHistoryIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "authId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create login history indexes: %w", err)
+	}
+
+	log.Println("✅ All login history indexes created successfully")
+	return nil
+}
+
+func SetupAuditLogIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "targetId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "action", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "createdAt", Value: -1}},
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create audit log indexes: %w", err)
+	}
+
+	log.Println("✅ All audit log indexes created successfully")
+	return nil
+}
+
+func SetupSessionIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "authId", Value: 1}, {Key: "lastSeenAt", Value: -1}},
+		},
+		{
+			// Sessions end with their last refresh token
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create session indexes: %w", err)
+	}
+
+	log.Println("✅ All session indexes created successfully")
+	return nil
+}
+
+func SetupAPIKeyIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "keyHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "authId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		{
+			// Keys without an expiry have no expiresAt and are kept
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create API key indexes: %w", err)
+	}
+
+	log.Println("✅ All API key indexes created successfully")
+	return nil
+}
+
+func SetupRefreshTokenIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "familyId", Value: 1}},
+		},
+		{
+			// Expired refresh tokens are useless, let MongoDB clean them up
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token indexes: %w", err)
+	}
+
+	log.Println("✅ All refresh token indexes created successfully")
+	return nil
+}
+
+func SetupRevokedTokenIndexes(collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "jti", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "sid", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+		{
+			Keys: bson.D{{Key: "authId", Value: 1}, {Key: "validAfter", Value: 1}},
+		},
+		{
+			// Revocations are only needed until the revoked tokens expire
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	_, err := collection.Indexes().CreateMany(context.TODO(), indexModels)
+	if err != nil {
+		return fmt.Errorf("failed to create revoked token indexes: %w", err)
+	}
+
+	log.Println("✅ All revoked token indexes created successfully")
+	return nil
+}
+
 func SetupAllIndexes() error {
 	var userCol = GetCollection(DB, "users")
 	if err := SetupUserIndexes(userCol); err != nil {
 		return fmt.Errorf("failed to setup user indexes: %w", err)
 	}
+	var authCol = GetCollection(DB, "auth")
+	if err := SetupAuthIndexes(authCol); err != nil {
+		return fmt.Errorf("failed to setup auth indexes: %w", err)
+	}
+	var webauthnSessionCol = GetCollection(DB, "webauthn_sessions")
+	if err := SetupWebAuthnSessionIndexes(webauthnSessionCol); err != nil {
+		return fmt.Errorf("failed to setup webauthn session indexes: %w", err)
+	}
+	var oauthStateCol = GetCollection(DB, "oauth_states")
+	if err := SetupOAuthStateIndexes(oauthStateCol); err != nil {
+		return fmt.Errorf("failed to setup oauth state indexes: %w", err)
+	}
+	var oauthClientCol = GetCollection(DB, "oauth_clients")
+	if err := SetupOAuthClientIndexes(oauthClientCol); err != nil {
+		return fmt.Errorf("failed to setup oauth client indexes: %w", err)
+	}
+	var authCodeCol = GetCollection(DB, "oauth_codes")
+	if err := SetupAuthorizationCodeIndexes(authCodeCol); err != nil {
+		return fmt.Errorf("failed to setup authorization code indexes: %w", err)
+	}
+	var loginAttemptCol = GetCollection(DB, "login_attempts")
+	if err := SetupLoginAttemptIndexes(loginAttemptCol); err != nil {
+		return fmt.Errorf("failed to setup login attempt indexes: %w", err)
+	}
+	var passwordResetCol = GetCollection(DB, "password_resets")
+	if err := SetupPasswordResetIndexes(passwordResetCol); err != nil {
+		return fmt.Errorf("failed to setup password reset indexes: %w", err)
+	}
+	var emailChangeCol = GetCollection(DB, "email_changes")
+	if err := SetupEmailChangeIndexes(emailChangeCol); err != nil {
+		return fmt.Errorf("failed to setup email change indexes: %w", err)
+	}
+	var loginHistoryCol = GetCollection(DB, "login_history")
+	if err := SetupLoginHistoryIndexes(loginHistoryCol); err != nil {
+		return fmt.Errorf("failed to setup login history indexes: %w", err)
+	}
+	var auditLogCol = GetCollection(DB, "audit_logs")
+	if err := SetupAuditLogIndexes(auditLogCol); err != nil {
+		return fmt.Errorf("failed to setup audit log indexes: %w", err)
+	}
+	var sessionCol = GetCollection(DB, "sessions")
+	if err := SetupSessionIndexes(sessionCol); err != nil {
+		return fmt.Errorf("failed to setup session indexes: %w", err)
+	}
+	var apiKeyCol = GetCollection(DB, "api_keys")
+	if err := SetupAPIKeyIndexes(apiKeyCol); err != nil {
+		return fmt.Errorf("failed to setup API key indexes: %w", err)
+	}
+	var refreshTokenCol = GetCollection(DB, "refresh_tokens")
+	if err := SetupRefreshTokenIndexes(refreshTokenCol); err != nil {
+		return fmt.Errorf("failed to setup refresh token indexes: %w", err)
+	}
+	var revokedTokenCol = GetCollection(DB, "revoked_tokens")
+	if err := SetupRevokedTokenIndexes(revokedTokenCol); err != nil {
+		return fmt.Errorf("failed to setup revoked token indexes: %w", err)
+	}
 	return nil
 }
@@ -3,6 +3,9 @@ package configs
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -18,8 +21,106 @@ type Config struct {
 	AWSBucketName string
 
 	// JWT Configuration
-	JWTSecret      string
-	JWTIssuer      string
+	JWTSecret       string
+	JWTIssuer       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// Asymmetric signing: JWTAlgorithm is HS256, RS256 or EdDSA. For the
+	// asymmetric algorithms keys are read from PEM files in JWTKeysDir.
+	JWTAlgorithm    string
+	JWTKeysDir      string
+	JWTSigningKeyID string
+
+	// Two-factor authentication
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+
+	// WebAuthn relying party
+	WebAuthnRPID    string
+	WebAuthnRPName  string
+	WebAuthnOrigins []string
+
+	// Magic link login
+	MagicLinkTTL time.Duration
+	MagicLinkURL string
+
+	// Password reset links
+	PasswordResetTTL time.Duration
+	PasswordResetURL string
+
+	// Email address changes
+	EmailChangeTTL       time.Duration
+	EmailChangeRevertTTL time.Duration
+	EmailChangeURL       string
+	EmailChangeRevertURL string
+
+	// Account deletion
+	AccountDeletionGracePeriod time.Duration
+	AccountPurgeInterval       time.Duration
+	EventsQueueName            string
+
+	// Social login. A provider is enabled when its client ID is set.
+	OAuthRedirectBaseURL string
+	GitHubOAuth          OAuthProviderConfig
+	GoogleOAuth          OAuthProviderConfig
+	OIDCOAuth            OAuthProviderConfig
+	OIDCProviderName     string
+
+	// OpenID Connect provider. JWTIssuer is the issuer URL; OIDCLoginURL is
+	// the frontend page that signs the user in and approves an authorization.
+	OIDCLoginURL string
+
+	// Accounts that always get the admin role, to bootstrap role assignment
+	AdminEmails []string
+
+	// Failed login throttling. Each failure doubles the wait before the next
+	// attempt (from LoginBackoffBase up to LoginBackoffMax) and the account is
+	// locked after LoginMaxFailures. IPs are only locked, never delayed.
+	LoginMaxFailures   int
+	LoginIPMaxFailures int
+	LoginLockDuration  time.Duration
+	LoginBackoffBase   time.Duration
+	LoginBackoffMax    time.Duration
+	LoginFailureWindow time.Duration
+
+	// How long sign-in attempts are kept for the admin login history
+	LoginHistoryRetention time.Duration
+
+	// Lifetime of the tokens admins get to act as another account. It can't
+	// exceed AccessTokenTTL, which is how long revocations are kept.
+	ImpersonationTTL time.Duration
+
+	// Email verification codes
+	OTPTTL            time.Duration
+	OTPMaxAttempts    int
+	OTPResendCooldown time.Duration
+
+	// Password policy. BreachedPasswordsDir holds the breached password hash
+	// prefix files, see password.BreachedList; empty disables the check.
+	PasswordMinLength    int
+	PasswordMaxLength    int
+	PasswordMinClasses   int
+	PasswordMinScore     int
+	PasswordHistorySize  int
+	BreachedPasswordsDir string
+
+	// Password hashing. PasswordHashAlgorithm is argon2id or bcrypt; hashes
+	// made with other settings are upgraded on the next login.
+	PasswordHashAlgorithm string
+	Argon2MemoryKiB       int
+	Argon2Iterations      int
+	Argon2Parallelism     int
+	BcryptCost            int
+}
+
+// OAuthProviderConfig is the client registration for an external identity
+// provider. Empty endpoints fall back to the provider's public defaults.
+type OAuthProviderConfig struct {
+	ClientID     string
+	ClientSecret string
+	AuthURL      string
+	TokenURL     string
+	UserInfoURL  string
 }
 
 func LoadEnv() Config {
@@ -28,7 +129,7 @@ func LoadEnv() Config {
 		log.Println(".env file not found, continuing")
 	}
 
-	return Config{
+	config := Config{
 		// MongoDB
 		MongoURI: os.Getenv("MONGOURI"),
 		// RabbitMQ
@@ -40,7 +141,162 @@ func LoadEnv() Config {
 		AWSBucketName: os.Getenv("AWS_S3_BUCKET"),
 
 		// JWT
-		JWTSecret:      os.Getenv("JWT_SECRET"),
-		JWTIssuer:      os.Getenv("JWT_ISSUER"),
+		JWTSecret:       os.Getenv("JWT_SECRET"),
+		JWTIssuer:       getEnv("JWT_ISSUER", "http://localhost:6400"),
+		AccessTokenTTL:  getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL: getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		JWTAlgorithm:    getEnv("JWT_ALGORITHM", "HS256"),
+		JWTKeysDir:      os.Getenv("JWT_KEYS_DIR"),
+		JWTSigningKeyID: os.Getenv("JWT_SIGNING_KID"),
+
+		// MFA
+		MFAIssuer:       getEnv("MFA_ISSUER", "ForgeIt"),
+		MFAChallengeTTL: getEnvDuration("MFA_CHALLENGE_TTL", 5*time.Minute),
+
+		// WebAuthn
+		WebAuthnRPID:    getEnv("WEBAUTHN_RP_ID", "localhost"),
+		WebAuthnRPName:  getEnv("WEBAUTHN_RP_NAME", "ForgeIt"),
+		WebAuthnOrigins: getEnvList("WEBAUTHN_ORIGINS", []string{"http://localhost:3000"}),
+
+		// Magic link
+		MagicLinkTTL: getEnvDuration("MAGIC_LINK_TTL", 15*time.Minute),
+		MagicLinkURL: getEnv("MAGIC_LINK_URL", "http://localhost:3000/auth/magic-link"),
+
+		// Password reset
+		PasswordResetTTL: getEnvDuration("PASSWORD_RESET_TTL", 15*time.Minute),
+		PasswordResetURL: getEnv("PASSWORD_RESET_URL", "http://localhost:3000/auth/reset-password"),
+
+		// Email change
+		EmailChangeTTL:       getEnvDuration("EMAIL_CHANGE_TTL", time.Hour),
+		EmailChangeRevertTTL: getEnvDuration("EMAIL_CHANGE_REVERT_TTL", 7*24*time.Hour),
+		EmailChangeURL:       getEnv("EMAIL_CHANGE_URL", "http://localhost:3000/auth/change-email/confirm"),
+		EmailChangeRevertURL: getEnv("EMAIL_CHANGE_REVERT_URL", "http://localhost:3000/auth/change-email/revert"),
+
+		// Account deletion
+		AccountDeletionGracePeriod: getEnvDuration("ACCOUNT_DELETION_GRACE_PERIOD", 14*24*time.Hour),
+		AccountPurgeInterval:       getEnvDuration("ACCOUNT_PURGE_INTERVAL", time.Hour),
+		EventsQueueName:            getEnv("EVENTS_QUEUE_NAME", "user_events"),
+
+		// Social login
+		OAuthRedirectBaseURL: getEnv("OAUTH_REDIRECT_BASE_URL", "http://localhost:6400"),
+		GitHubOAuth:          loadOAuthProvider("GITHUB"),
+		GoogleOAuth:          loadOAuthProvider("GOOGLE"),
+		OIDCOAuth:            loadOAuthProvider("OIDC"),
+		OIDCProviderName:     getEnv("OIDC_PROVIDER_NAME", "oidc"),
+
+		// OpenID Connect provider
+		OIDCLoginURL: getEnv("OIDC_LOGIN_URL", "http://localhost:3000/oauth/authorize"),
+
+		AdminEmails: getEnvList("ADMIN_EMAILS", nil),
+
+		// Failed login throttling
+		LoginMaxFailures:   getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures: getEnvInt("LOGIN_IP_MAX_FAILURES", 50),
+		LoginLockDuration:  getEnvDuration("LOGIN_LOCK_DURATION", 15*time.Minute),
+		LoginBackoffBase:   getEnvDuration("LOGIN_BACKOFF_BASE", time.Second),
+		LoginBackoffMax:    getEnvDuration("LOGIN_BACKOFF_MAX", time.Minute),
+		LoginFailureWindow: getEnvDuration("LOGIN_FAILURE_WINDOW", time.Hour),
+
+		LoginHistoryRetention: getEnvDuration("LOGIN_HISTORY_RETENTION", 90*24*time.Hour),
+
+		// Admin impersonation
+		ImpersonationTTL: getEnvDuration("IMPERSONATION_TTL", 15*time.Minute),
+
+		// Email verification codes
+		OTPTTL:            getEnvDuration("OTP_TTL", 15*time.Minute),
+		OTPMaxAttempts:    getEnvInt("OTP_MAX_ATTEMPTS", 5),
+		OTPResendCooldown: getEnvDuration("OTP_RESEND_COOLDOWN", time.Minute),
+
+		// Password policy
+		PasswordMinLength:    getEnvInt("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxLength:    getEnvInt("PASSWORD_MAX_LENGTH", 128),
+		PasswordMinClasses:   getEnvInt("PASSWORD_MIN_CLASSES", 3),
+		PasswordMinScore:     getEnvInt("PASSWORD_MIN_SCORE", 2),
+		PasswordHistorySize:  getEnvInt("PASSWORD_HISTORY_SIZE", 5),
+		BreachedPasswordsDir: os.Getenv("BREACHED_PASSWORDS_DIR"),
+
+		// Password hashing
+		PasswordHashAlgorithm: getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		Argon2MemoryKiB:       getEnvInt("ARGON2_MEMORY_KIB", 19*1024),
+		Argon2Iterations:      getEnvInt("ARGON2_ITERATIONS", 2),
+		Argon2Parallelism:     getEnvInt("ARGON2_PARALLELISM", 1),
+		BcryptCost:            getEnvInt("BCRYPT_COST", 12),
+	}
+
+	if config.ImpersonationTTL > config.AccessTokenTTL {
+		log.Printf("⚠️ IMPERSONATION_TTL %v exceeds ACCESS_TOKEN_TTL, using %v", config.ImpersonationTTL, config.AccessTokenTTL)
+		config.ImpersonationTTL = config.AccessTokenTTL
+	}
+	// The purge job runs on a ticker, which panics on intervals that aren't
+	// positive
+	if config.AccountPurgeInterval <= 0 {
+		log.Printf("⚠️ ACCOUNT_PURGE_INTERVAL %v must be positive, using %v", config.AccountPurgeInterval, time.Hour)
+		config.AccountPurgeInterval = time.Hour
+	}
+	return config
+}
+
+// loadOAuthProvider reads <PREFIX>_CLIENT_ID, <PREFIX>_CLIENT_SECRET and the
+// optional endpoint overrides for one provider.
+func loadOAuthProvider(prefix string) OAuthProviderConfig {
+	return OAuthProviderConfig{
+		ClientID:     os.Getenv(prefix + "_CLIENT_ID"),
+		ClientSecret: os.Getenv(prefix + "_CLIENT_SECRET"),
+		AuthURL:      os.Getenv(prefix + "_AUTH_URL"),
+		TokenURL:     os.Getenv(prefix + "_TOKEN_URL"),
+		UserInfoURL:  os.Getenv(prefix + "_USERINFO_URL"),
+	}
+}
+
+// getEnv returns the value of key, or def when it is unset.
+func getEnv(key string, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// getEnvList reads a comma separated list from the environment.
+func getEnvList(key string, def []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvInt reads an integer from the environment, falling back to def when
+// the variable is unset or malformed.
+func getEnvInt(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid integer for %s (%q), using default %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// getEnvDuration reads a Go duration string (e.g. "15m") from the environment,
+// falling back to def when the variable is unset or malformed.
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("⚠️ Invalid duration for %s (%q), using default %v", key, value, def)
+		return def
 	}
+	return d
 }
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// A purge that hasn't finished after this long is assumed to have crashed and
+// is picked up again.
+const accountPurgeLease = 15 * time.Minute
+
+var errAccountBeingPurged = errors.New("account is being deleted")
+
+// DeleteAccount schedules the signed in account for deletion after the grace
+// period. Logging in again before then cancels it.
+func DeleteAccount(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.DeleteAccountRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "User not found", nil)
+	}
+
+	wait, locked, err := loginRetryAfter(ctx, user.Email, c.IP())
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to check login attempts", map[string]string{"error": err.Error()})
+	}
+	if wait > 0 {
+		return rejectThrottledLogin(c, wait, locked)
+	}
+	if !checkPassword(user.Password, req.Password) {
+		recordLoginFailure(ctx, user.Email, c.IP())
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Password is incorrect", nil)
+	}
+	clearLoginFailures(ctx, user.Email)
+
+	now := time.Now()
+	deleteAfter := now.Add(config.AccountDeletionGracePeriod)
+	_, err = authCol.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{
+		"deletionRequestedAt": now,
+		"deleteAfter":         deleteAfter,
+	}})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to schedule account deletion", map[string]string{"error": err.Error()})
+	}
+
+	// Signing out everywhere means any later login is a deliberate cancel
+	if err := revokeAllTokens(ctx, user.ID); err != nil {
+		log.Printf("⚠️ Failed to revoke sessions of %s after deletion request: %v", user.Email, err)
+	}
+	sendAccountDeletionEmail(user.Email, "Your account is scheduled for deletion", "account_deletion_scheduled", deleteAfter)
+
+	log.Printf("⚠️ Account %s scheduled for deletion after %s", user.Email, deleteAfter.Format(time.RFC3339))
+	recordAudit(c, auditAccountDeletionRequest, auditSuccess, user.ID, user.Email, map[string]string{"deleteAfter": deleteAfter.Format(time.RFC3339)})
+	return responses.SendSuccessResponse(c, fiber.StatusAccepted, "Account scheduled for deletion, log in again before then to cancel", fiber.Map{
+		"deleteAfter": deleteAfter,
+	})
+}
+
+// cancelAccountDeletion is called on every new login. It fails only while a
+// purge of the account is running; a purge that failed can still be
+// cancelled.
+func cancelAccountDeletion(ctx context.Context, user models.Auth) error {
+	if user.DeleteAfter == nil {
+		return nil
+	}
+
+	result, err := authCol.UpdateOne(ctx,
+		bson.M{"_id": user.ID, "purgeStartedAt": bson.M{"$exists": false}},
+		bson.M{"$unset": bson.M{"deletionRequestedAt": "", "deleteAfter": "", "purgeFailedAt": "", "purgeError": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errAccountBeingPurged
+	}
+
+	log.Printf("✅ Account deletion of %s cancelled by login", user.Email)
+	sendAccountDeletionEmail(user.Email, "Your account deletion was cancelled", "account_deletion_cancelled", *user.DeleteAfter)
+	return nil
+}
+
+func sendAccountDeletionEmail(email string, subject string, template string, deleteAfter time.Time) {
+	if producer == nil {
+		log.Println("⚠️ RabbitMQ producer not initialized, skipping email notification")
+		return
+	}
+
+	emailData := structure.EmailData{
+		To:       email,
+		Subject:  subject,
+		Template: template,
+		Data: map[string]string{
+			"deleteAfter": deleteAfter.Format(time.RFC1123),
+		},
+	}
+	if err := producer.Publish(context.Background(), emailData); err != nil {
+		log.Printf("❌ Failed to publish %s email: %v", template, err)
+	}
+}
+
+// StartAccountPurgeJob deletes accounts whose grace period has ended, once
+// now and then every AccountPurgeInterval.
+func StartAccountPurgeJob() {
+	go func() {
+		ticker := time.NewTicker(config.AccountPurgeInterval)
+		defer ticker.Stop()
+		for {
+			purgeDueAccounts()
+			<-ticker.C
+		}
+	}()
+}
+
+func purgeDueAccounts() {
+	runStartedAt := time.Now()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+
+		// Claiming the account first keeps several instances from purging it
+		// at once, and stops logins from cancelling a purge half way through.
+		// Accounts that failed during this run wait for the next one.
+		now := time.Now()
+		var user models.Auth
+		err := authCol.FindOneAndUpdate(ctx,
+			bson.M{
+				"deleteAfter":   bson.M{"$lte": now},
+				"purgeFailedAt": bson.M{"$not": bson.M{"$gte": runStartedAt}},
+				"$or": []bson.M{
+					{"purgeStartedAt": bson.M{"$exists": false}},
+					{"purgeStartedAt": bson.M{"$lt": now.Add(-accountPurgeLease)}},
+				},
+			},
+			bson.M{"$set": bson.M{"purgeStartedAt": now}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&user)
+		if err != nil {
+			cancel()
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				log.Printf("❌ Failed to find accounts to purge: %v", err)
+			}
+			return
+		}
+
+		if err := purgeAccount(ctx, user); err != nil {
+			log.Printf("❌ Failed to purge account %s, will retry: %v", user.Email, err)
+			releasePurge(ctx, user, err)
+		}
+		cancel()
+	}
+}
+
+// releasePurge gives up the claim on an account whose purge failed, so its
+// owner can still cancel the deletion by logging in until the next run.
+func releasePurge(ctx context.Context, user models.Auth, purgeErr error) {
+	_, err := authCol.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"$set":   bson.M{"purgeFailedAt": time.Now(), "purgeError": purgeErr.Error()},
+		"$unset": bson.M{"purgeStartedAt": ""},
+	})
+	if err != nil {
+		log.Printf("❌ Failed to release purge of account %s: %v", user.Email, err)
+	}
+}
+
+// purgeAccount removes everything stored for an account. The auth document
+// goes last, so a purge that fails half way is retried from the start.
+func purgeAccount(ctx context.Context, user models.Auth) error {
+	var profile models.User
+	profileFilter := bson.M{"$or": []bson.M{{"authId": user.ID}, {"email": user.Email}}}
+	err := userCollection.FindOne(ctx, profileFilter).Decode(&profile)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return fmt.Errorf("failed to load profile: %w", err)
+	}
+	if err == nil {
+		if profile.Resume != "" {
+			s3Client, bucketName := utils.InitS3()
+			err := utils.DeleteFromS3(s3Client, bucketName, profile.Resume)
+			if errors.Is(err, utils.ErrNotInBucket) {
+				// Nothing of ours to delete
+				log.Printf("⚠️ Resume of %s is not in the bucket, skipping: %s", user.Email, profile.Resume)
+			} else if err != nil {
+				return fmt.Errorf("failed to delete resume: %w", err)
+			}
+		}
+		if _, err := userCollection.DeleteOne(ctx, profileFilter); err != nil {
+			return fmt.Errorf("failed to delete profile: %w", err)
+		}
+	}
+
+	if err := revokeAllTokens(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+	for _, col := range []*mongo.Collection{refreshTokenCol, sessionCol, apiKeyCol, passwordResetCol, emailChangeCol, authCodeCol, loginHistoryCol} {
+		if _, err := col.DeleteMany(ctx, bson.M{"authId": user.ID}); err != nil {
+			return fmt.Errorf("failed to clean up %s: %w", col.Name(), err)
+		}
+	}
+	if _, err := loginAttemptCol.DeleteOne(ctx, bson.M{"_id": accountAttemptKey(user.Email)}); err != nil {
+		return fmt.Errorf("failed to clean up login attempts: %w", err)
+	}
+
+	if eventProducer == nil {
+		return errors.New("event producer not initialized")
+	}
+	event := structure.UserEvent{
+		Event:      structure.EventUserDeleted,
+		AuthID:     user.ID.Hex(),
+		Email:      user.Email,
+		OccurredAt: time.Now(),
+	}
+	if err := eventProducer.Publish(ctx, event); err != nil {
+		return fmt.Errorf("failed to publish %s event: %w", structure.EventUserDeleted, err)
+	}
+
+	if _, err := authCol.DeleteOne(ctx, bson.M{"_id": user.ID}); err != nil {
+		return fmt.Errorf("failed to delete auth record: %w", err)
+	}
+
+	log.Printf("✅ Account %s purged", user.Email)
+	return nil
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/structure"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// scheduleDeletion marks user for deletion as if the grace period had ended
+// at deleteAfter.
+func scheduleDeletion(t *testing.T, user models.Auth, deleteAfter time.Time, set bson.M) {
+	update := bson.M{"deletionRequestedAt": deleteAfter.Add(-config.AccountDeletionGracePeriod), "deleteAfter": deleteAfter}
+	for k, v := range set {
+		update[k] = v
+	}
+	_, err := authCol.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$set": update})
+	require.NoError(t, err)
+}
+
+func TestDeleteAccount_SchedulesAndSignsOut(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	resp := sendJSON(t, app, http.MethodPost, "/auth/delete-account", tokens.Token, structure.DeleteAccountRequest{Password: "wrong" + password})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = sendJSON(t, app, http.MethodPost, "/auth/delete-account", tokens.Token, structure.DeleteAccountRequest{Password: password})
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	stored, err := findAuthByID(context.TODO(), user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored.DeleteAfter)
+	assert.WithinDuration(t, time.Now().Add(config.AccountDeletionGracePeriod), *stored.DeleteAfter, time.Minute)
+
+	resp = refresh(t, app, tokens.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestDeleteAccount_LoginCancels(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	scheduleDeletion(t, user, time.Now().Add(time.Hour), nil)
+
+	login(t, app, user.Email, password)
+
+	stored, err := findAuthByID(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.DeleteAfter)
+	assert.Nil(t, stored.DeletionRequestedAt)
+}
+
+func TestDeleteAccount_LoginDuringPurge(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	scheduleDeletion(t, user, time.Now().Add(-time.Hour), bson.M{"purgeStartedAt": time.Now()})
+
+	resp := sendJSON(t, app, http.MethodPost, "/auth/login", "", models.Auth{Email: user.Email, Password: password})
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+}
+
+func TestPurgeDueAccounts_DeletesAccount(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	login(t, app, user.Email, password)
+	scheduleDeletion(t, user, time.Now().Add(-time.Minute), nil)
+
+	purgeDueAccounts()
+
+	_, err := findAuthByID(context.TODO(), user.ID)
+	assert.Error(t, err)
+	for _, col := range []string{"refresh_tokens", "sessions", "login_history"} {
+		count, err := authCol.Database().Collection(col).CountDocuments(context.TODO(), bson.M{"authId": user.ID})
+		require.NoError(t, err)
+		assert.Zero(t, count, col)
+	}
+}
+
+func TestPurgeDueAccounts_ReleasesFailedPurge(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	scheduleDeletion(t, user, time.Now().Add(-time.Minute), nil)
+
+	// Publishing the deletion event fails without a producer
+	previous := eventProducer
+	eventProducer = nil
+	t.Cleanup(func() { eventProducer = previous })
+
+	purgeDueAccounts()
+
+	stored, err := findAuthByID(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.PurgeStartedAt)
+	assert.NotNil(t, stored.PurgeFailedAt)
+	assert.NotEmpty(t, stored.PurgeError)
+
+	// Until the next run the owner can still take the deletion back
+	login(t, app, user.Email, password)
+	stored, err = findAuthByID(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.DeleteAfter)
+	assert.Nil(t, stored.PurgeFailedAt)
+}
@@ -0,0 +1,395 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/rbac"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// accountView is what support staff see of an account. Secrets such as the
+// password hash and MFA seeds are left out.
+func accountView(user models.Auth, profile *models.User) fiber.Map {
+	return fiber.Map{
+		"id":                  user.ID,
+		"email":               user.Email,
+		"isVerified":          user.IsVerified,
+		"mfaEnabled":          user.MFAEnabled,
+		"passkeys":            len(user.WebAuthnCredentials),
+		"identities":          user.Identities,
+		"roles":               utils.AccountRoles(user),
+		"permissions":         user.Permissions,
+		"disabled":            user.Disabled,
+		"disabledAt":          user.DisabledAt,
+		"disabledReason":      user.DisabledReason,
+		"deletionRequestedAt": user.DeletionRequestedAt,
+		"deleteAfter":         user.DeleteAfter,
+		"profile":             profile,
+	}
+}
+
+// findProfileOf returns the profile of an account, or nil if it has none.
+func findProfileOf(ctx context.Context, user models.Auth) (*models.User, error) {
+	var profile models.User
+	err := userCollection.FindOne(ctx, bson.M{"$or": []bson.M{{"authId": user.ID}, {"email": user.Email}}}).Decode(&profile)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// SearchAccounts lists accounts, optionally filtered by part of the email and
+// by verification or disabled status.
+func SearchAccounts(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if email := c.Query("email"); email != "" {
+		filter["email"] = bson.M{"$regex": regexp.QuoteMeta(email), "$options": "i"}
+	}
+	for param, field := range map[string]string{"verified": "isVerified", "disabled": "disabled"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return responses.SendValidationError(c, map[string]string{param: "must be true or false"})
+		}
+		if b {
+			filter[field] = true
+		} else {
+			filter[field] = bson.M{"$ne": true}
+		}
+	}
+	page, limit := pageParams(c)
+
+	total, err := authCol.CountDocuments(ctx, filter)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to search accounts", map[string]string{"error": err.Error()})
+	}
+	cursor, err := authCol.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "email", Value: 1}}).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to search accounts", map[string]string{"error": err.Error()})
+	}
+	var users []models.Auth
+	if err := cursor.All(ctx, &users); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to search accounts", map[string]string{"error": err.Error()})
+	}
+
+	accounts := make([]fiber.Map, 0, len(users))
+	for _, user := range users {
+		profile, err := findProfileOf(ctx, user)
+		if err != nil {
+			return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to load profile", map[string]string{"error": err.Error()})
+		}
+		accounts = append(accounts, accountView(user, profile))
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "success", fiber.Map{
+		"data":  accounts,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
+
+func GetAccount(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := primitive.ObjectIDFromHex(c.Params("authId"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid account ID", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Account not found", nil)
+	}
+	profile, err := findProfileOf(ctx, user)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to load profile", map[string]string{"error": err.Error()})
+	}
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "success", fiber.Map{"data": accountView(user, profile)})
+}
+
+// ForceVerifyAccount marks an account's email as verified without an OTP.
+func ForceVerifyAccount(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := primitive.ObjectIDFromHex(c.Params("authId"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid account ID", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Account not found", nil)
+	}
+	_, err = authCol.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"$set":   bson.M{"isVerified": true},
+		"$unset": bson.M{"otp": "", "otpExpiresAt": "", "otpAttempts": ""},
+	})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to verify account", map[string]string{"error": err.Error()})
+	}
+
+	log.Printf("✅ Account %s force-verified by %v", user.Email, c.Locals("email"))
+	recordAudit(c, auditAdminVerify, auditSuccess, user.ID, user.Email, nil)
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Account verified", nil)
+}
+
+// AdminResendOTP sends a new verification code, skipping the user-facing
+// cooldown.
+func AdminResendOTP(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := primitive.ObjectIDFromHex(c.Params("authId"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid account ID", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Account not found", nil)
+	}
+	if user.IsVerified {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Account is already verified", nil)
+	}
+	if producer == nil {
+		log.Println("⚠️ RabbitMQ producer not initialized, skipping email notification")
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Email service unavailable", nil)
+	}
+
+	otp, otpHash, err := newVerificationOTP()
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to generate OTP", map[string]string{"error": err.Error()})
+	}
+	now := time.Now()
+	_, err = authCol.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"$set":   bson.M{"otp": otpHash, "otpExpiresAt": now.Add(config.OTPTTL), "otpSentAt": now},
+		"$unset": bson.M{"otpAttempts": ""},
+	})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to generate OTP", map[string]string{"error": err.Error()})
+	}
+	if err := sendVerificationOTP(user.Email, otp); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to send verification email", map[string]string{"error": err.Error()})
+	}
+
+	recordAudit(c, auditAdminResendOTP, auditSuccess, user.ID, user.Email, nil)
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "OTP sent", nil)
+}
+
+// AdminSendPasswordReset emails the account a password reset link.
+func AdminSendPasswordReset(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := primitive.ObjectIDFromHex(c.Params("authId"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid account ID", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Account not found", nil)
+	}
+	if producer == nil {
+		log.Println("⚠️ RabbitMQ producer not initialized, skipping email notification")
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Email service unavailable", nil)
+	}
+
+	token, err := newPasswordReset(ctx, user)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to create password reset", map[string]string{"error": err.Error()})
+	}
+	if err := sendPasswordResetEmail(ctx, user, token); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to send password reset email", map[string]string{"error": err.Error()})
+	}
+
+	recordAudit(c, auditAdminPasswordReset, auditSuccess, user.ID, user.Email, nil)
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Password reset link sent", nil)
+}
+
+// DisableAccount stops an account from signing in and ends its sessions.
+func DisableAccount(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.DisableAccountRequest
+	if err := c.BodyParser(&req); err != nil && len(c.Body()) > 0 {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+
+	authID, err := primitive.ObjectIDFromHex(c.Params("authId"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid account ID", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Account not found", nil)
+	}
+	_, err = authCol.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{
+		"disabled":       true,
+		"disabledAt":     time.Now(),
+		"disabledReason": req.Reason,
+	}})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to disable account", map[string]string{"error": err.Error()})
+	}
+	if err := revokeAllTokens(ctx, user.ID); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to revoke sessions", map[string]string{"error": err.Error()})
+	}
+
+	log.Printf("🚨 Account %s disabled by %v: %s", user.Email, c.Locals("email"), req.Reason)
+	recordAudit(c, auditAdminDisable, auditSuccess, user.ID, user.Email, map[string]string{"reason": req.Reason})
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Account disabled", nil)
+}
+
+func EnableAccount(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := primitive.ObjectIDFromHex(c.Params("authId"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid account ID", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Account not found", nil)
+	}
+	_, err = authCol.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"$unset": bson.M{"disabled": "", "disabledAt": "", "disabledReason": ""},
+	})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to enable account", map[string]string{"error": err.Error()})
+	}
+
+	log.Printf("✅ Account %s enabled by %v", user.Email, c.Locals("email"))
+	recordAudit(c, auditAdminEnable, auditSuccess, user.ID, user.Email, nil)
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Account enabled", nil)
+}
+
+// RevokeAccountSessions signs an account out of every device.
+func RevokeAccountSessions(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := primitive.ObjectIDFromHex(c.Params("authId"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid account ID", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Account not found", nil)
+	}
+	if err := revokeAllTokens(ctx, user.ID); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to revoke sessions", map[string]string{"error": err.Error()})
+	}
+
+	log.Printf("✅ Sessions of %s revoked by %v", user.Email, c.Locals("email"))
+	recordAudit(c, auditAdminRevokeSessions, auditSuccess, user.ID, user.Email, nil)
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Sessions revoked", nil)
+}
+
+// Impersonate gives a support admin a short-lived access token for another
+// account, to see the service as its owner does. The token names the admin in
+// its act claim, can't be refreshed and is kept away from credentials and
+// other sensitive settings, see middleware.BlockImpersonation.
+func Impersonate(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.ImpersonateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	adminID, err := realAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Unauthorized", nil)
+	}
+	adminEmail, _ := c.Locals("realEmail").(string)
+
+	authID, err := primitive.ObjectIDFromHex(c.Params("authId"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid account ID", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Account not found", nil)
+	}
+
+	// Only plain users can be impersonated, so admins can't borrow another
+	// account's extra permissions. Disabled accounts can't be used at all.
+	roles := utils.AccountRoles(user)
+	permissions := rbac.Permissions(roles, user.Permissions)
+	refusal := ""
+	switch {
+	case user.ID == adminID:
+		refusal = "You can't impersonate yourself"
+	case rbac.Has(roles, rbac.RoleAdmin):
+		refusal = "Admin accounts can't be impersonated"
+	case rbac.Exceeds(permissions, rbac.RoleUser):
+		refusal = "Accounts with extra permissions can't be impersonated"
+	case user.Disabled:
+		refusal = "Account is disabled"
+	}
+	if refusal != "" {
+		recordAudit(c, auditAdminImpersonate, auditFailure, user.ID, user.Email, map[string]string{"reason": req.Reason, "error": refusal})
+		return responses.SendErrorResponse(c, fiber.StatusForbidden, responses.ErrCodeForbidden, refusal, nil)
+	}
+
+	token, expiresAt, err := utils.GenerateImpersonationToken(user.ID.Hex(), user.Email, roles, permissions, adminID.Hex(), adminEmail, config.ImpersonationTTL)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to sign token", map[string]string{"error": err.Error()})
+	}
+
+	log.Printf("🕵️ %s is impersonating %s until %s: %s", adminEmail, user.Email, expiresAt.Format(time.RFC3339), req.Reason)
+	recordAudit(c, auditAdminImpersonate, auditSuccess, user.ID, user.Email, map[string]string{
+		"reason":    req.Reason,
+		"expiresAt": expiresAt.Format(time.RFC3339),
+	})
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Impersonation token issued", fiber.Map{
+		"token":     token,
+		"tokenType": "Bearer",
+		"expiresIn": int(time.Until(expiresAt).Seconds()),
+		"account": fiber.Map{
+			"id":    user.ID,
+			"email": user.Email,
+		},
+	})
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"user-auth-profile-service/src/rbac"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func impersonate(t *testing.T, app *fiber.App, adminToken string, authID string) *http.Response {
+	return sendJSON(t, app, http.MethodPost, "/admin/impersonate/"+authID, adminToken, structure.ImpersonateRequest{Reason: "Support ticket 42"})
+}
+
+func TestImpersonate_IssuesActorToken(t *testing.T) {
+	app := setupAuthApp()
+	admin, adminPassword := createVerifiedUser(t, rbac.RoleAdmin)
+	user, _ := createVerifiedUser(t)
+	adminTokens := login(t, app, admin.Email, adminPassword)
+
+	resp := impersonate(t, app, adminTokens.Token, user.ID.Hex())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var data struct {
+		Token string `json:"token"`
+	}
+	decodeData(t, resp, &data)
+
+	claims, err := utils.ParseJWT(data.Token)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID.Hex(), claims["sub"])
+	actorID, actorEmail, ok := utils.Actor(claims)
+	assert.True(t, ok)
+	assert.Equal(t, admin.ID.Hex(), actorID)
+	assert.Equal(t, admin.Email, actorEmail)
+	assert.Nil(t, claims["sid"], "impersonation tokens can't be refreshed")
+
+	// The token reads the account but can't touch its credentials or sessions
+	resp = sendJSON(t, app, http.MethodGet, "/auth/sessions", data.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = sendJSON(t, app, http.MethodPost, "/auth/logout-all", data.Token, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = sendJSON(t, app, http.MethodPatch, "/auth/update-password", data.Token, structure.UpdatePasswordRequest{
+		CurrentPassword: "unknown",
+		NewPassword:     randomPassword(),
+	})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestImpersonate_Refusals(t *testing.T) {
+	app := setupAuthApp()
+	admin, adminPassword := createVerifiedUser(t, rbac.RoleAdmin)
+	otherAdmin, _ := createVerifiedUser(t, rbac.RoleAdmin)
+	privileged, _ := createVerifiedUser(t)
+	_, err := authCol.UpdateOne(context.TODO(), bson.M{"_id": privileged.ID}, bson.M{"$set": bson.M{"permissions": []string{rbac.PermUsersRead}}})
+	require.NoError(t, err)
+	disabled, _ := createVerifiedUser(t)
+	_, err = authCol.UpdateOne(context.TODO(), bson.M{"_id": disabled.ID}, bson.M{"$set": bson.M{"disabled": true}})
+	require.NoError(t, err)
+	adminTokens := login(t, app, admin.Email, adminPassword)
+
+	for name, authID := range map[string]string{
+		"self":              admin.ID.Hex(),
+		"admin":             otherAdmin.ID.Hex(),
+		"extra permissions": privileged.ID.Hex(),
+		"disabled":          disabled.ID.Hex(),
+	} {
+		resp := impersonate(t, app, adminTokens.Token, authID)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, name)
+	}
+}
+
+func TestImpersonate_RequiresAdmin(t *testing.T) {
+	app := setupAuthApp()
+	caller, password := createVerifiedUser(t)
+	user, _ := createVerifiedUser(t)
+	tokens := login(t, app, caller.Email, password)
+
+	resp := impersonate(t, app, tokens.Token, user.ID.Hex())
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/rbac"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var apiKeyCol = configs.GetCollection(configs.DB, "api_keys")
+
+// Enough of the key to tell keys apart in a list without weakening it
+const apiKeyDisplayLength = len(utils.APIKeyPrefix) + 8
+
+// CreateAPIKey creates an API key for the signed in account. The key is only
+// returned here, afterwards just its prefix is shown.
+func CreateAPIKey(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// A leaked key mustn't be enough to mint more keys
+	if currentClaims(c)["token_use"] == utils.TokenUseAPIKey {
+		return responses.SendErrorResponse(c, fiber.StatusForbidden, responses.ErrCodeForbidden, "API keys can't be used to create API keys", nil)
+	}
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+
+	var req structure.CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+	for _, scope := range req.Scopes {
+		if !rbac.IsPermission(scope) {
+			return responses.SendValidationError(c, map[string]string{"Scopes": fmt.Sprintf("unknown scope '%s'", scope)})
+		}
+		if !hasPermission(c, scope) {
+			return responses.SendValidationError(c, map[string]string{"Scopes": fmt.Sprintf("you don't have the '%s' permission", scope)})
+		}
+	}
+	now := time.Now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return responses.SendValidationError(c, map[string]string{"ExpiresAt": "must be in the future"})
+	}
+
+	key, err := utils.GenerateAPIKey()
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to generate API key", map[string]string{"error": err.Error()})
+	}
+	apiKey := models.APIKey{
+		AuthID:    authID,
+		Name:      req.Name,
+		Prefix:    key[:apiKeyDisplayLength],
+		KeyHash:   utils.HashToken(key),
+		Scopes:    req.Scopes,
+		CreatedAt: now,
+		ExpiresAt: req.ExpiresAt,
+	}
+	result, err := apiKeyCol.InsertOne(ctx, apiKey)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to create API key", map[string]string{"error": err.Error()})
+	}
+	apiKey.ID, _ = result.InsertedID.(primitive.ObjectID)
+
+	email, _ := c.Locals("email").(string)
+	recordAudit(c, auditAPIKeyCreate, auditSuccess, authID, email, map[string]string{"keyId": apiKey.ID.Hex(), "name": apiKey.Name})
+	return responses.SendSuccessResponse(c, fiber.StatusCreated, "API key created, copy it now as it won't be shown again", fiber.Map{
+		"key":    key,
+		"apiKey": apiKey,
+	})
+}
+
+// ListAPIKeys lists the signed in account's API keys that haven't been
+// revoked, newest first.
+func ListAPIKeys(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+
+	cursor, err := apiKeyCol.Find(ctx,
+		bson.M{"authId": authID, "revokedAt": bson.M{"$exists": false}},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to fetch API keys", map[string]string{"error": err.Error()})
+	}
+	keys := []models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to fetch API keys", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "success", fiber.Map{"data": keys})
+}
+
+// RevokeAPIKey stops one of the signed in account's API keys from working.
+func RevokeAPIKey(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	keyID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "API key not found", nil)
+	}
+
+	result, err := apiKeyCol.UpdateOne(ctx,
+		bson.M{"_id": keyID, "authId": authID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to revoke API key", map[string]string{"error": err.Error()})
+	}
+	if result.MatchedCount == 0 {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "API key not found", nil)
+	}
+
+	email, _ := c.Locals("email").(string)
+	recordAudit(c, auditAPIKeyRevoke, auditSuccess, authID, email, map[string]string{"keyId": keyID.Hex()})
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "API key revoked", nil)
+}
+
+// revokeAllAPIKeys stops every API key of the account from working. Keys
+// outlive logouts, but not the recovery of a compromised account, since
+// whoever held a stolen session may have created some.
+func revokeAllAPIKeys(ctx context.Context, authID primitive.ObjectID) error {
+	_, err := apiKeyCol.UpdateMany(ctx,
+		bson.M{"authId": authID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	return err
+}
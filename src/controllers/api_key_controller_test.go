@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"user-auth-profile-service/src/middleware"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/rbac"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// setupAPIKeyApp adds a route showing the permissions AuthMiddleware granted
+// the caller.
+func setupAPIKeyApp() *fiber.App {
+	app := setupAuthApp()
+	app.Get("/test/permissions", middleware.AuthMiddleware, func(c *fiber.Ctx) error {
+		return c.JSON(utils.ClaimStrings(currentClaims(c), "permissions"))
+	})
+	return app
+}
+
+func createAPIKey(t *testing.T, app *fiber.App, token string, scopes ...string) (string, models.APIKey) {
+	resp := sendJSON(t, app, http.MethodPost, "/auth/api-keys", token, structure.CreateAPIKeyRequest{Name: "ci", Scopes: scopes})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var data struct {
+		Key    string        `json:"key"`
+		APIKey models.APIKey `json:"apiKey"`
+	}
+	decodeData(t, resp, &data)
+	return data.Key, data.APIKey
+}
+
+func permissionsOf(t *testing.T, app *fiber.App, token string) []string {
+	resp := sendJSON(t, app, http.MethodGet, "/test/permissions", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var permissions []string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&permissions))
+	return permissions
+}
+
+func TestCreateAPIKey_Authenticates(t *testing.T) {
+	app := setupAPIKeyApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	key, apiKey := createAPIKey(t, app, tokens.Token, rbac.PermProfileRead)
+	assert.True(t, strings.HasPrefix(key, utils.APIKeyPrefix))
+	assert.True(t, strings.HasPrefix(key, apiKey.Prefix))
+	assert.Equal(t, []string{rbac.PermProfileRead}, permissionsOf(t, app, key))
+
+	resp := sendJSON(t, app, http.MethodGet, "/auth/api-keys", key, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var stored models.APIKey
+	require.NoError(t, apiKeyCol.FindOne(context.TODO(), bson.M{"_id": apiKey.ID}).Decode(&stored))
+	assert.NotNil(t, stored.LastUsedAt)
+	assert.NotEqual(t, key, stored.KeyHash, "only the hash of the key is stored")
+}
+
+func TestCreateAPIKey_BlockedRoutes(t *testing.T) {
+	app := setupAPIKeyApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+	key, _ := createAPIKey(t, app, tokens.Token, rbac.PermProfileRead)
+
+	resp := sendJSON(t, app, http.MethodPost, "/auth/api-keys", key, structure.CreateAPIKeyRequest{Name: "more", Scopes: []string{rbac.PermProfileRead}})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = sendJSON(t, app, http.MethodGet, "/auth/sessions", key, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = sendJSON(t, app, http.MethodPost, "/auth/logout-all", key, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = sendJSON(t, app, http.MethodPatch, "/auth/update-password", key, structure.UpdatePasswordRequest{
+		CurrentPassword: password,
+		NewPassword:     randomPassword(),
+	})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = sendJSON(t, app, http.MethodPost, "/auth/delete-account", key, structure.DeleteAccountRequest{Password: password})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestCreateAPIKey_ValidatesScopes(t *testing.T) {
+	app := setupAPIKeyApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	for name, req := range map[string]structure.CreateAPIKeyRequest{
+		"no scopes":          {Name: "ci"},
+		"unknown scope":      {Name: "ci", Scopes: []string{"everything"}},
+		"scope not held":     {Name: "ci", Scopes: []string{rbac.PermUsersRead}},
+		"expiry in the past": {Name: "ci", Scopes: []string{rbac.PermProfileRead}, ExpiresAt: timePtr(time.Now().Add(-time.Hour))},
+	} {
+		resp := sendJSON(t, app, http.MethodPost, "/auth/api-keys", tokens.Token, req)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, name)
+	}
+}
+
+func TestAPIKey_ScopesNarrowWhenRoleIsRemoved(t *testing.T) {
+	app := setupAPIKeyApp()
+	user, password := createVerifiedUser(t, rbac.RoleAdmin)
+	tokens := login(t, app, user.Email, password)
+	key, _ := createAPIKey(t, app, tokens.Token, rbac.PermUsersRead, rbac.PermProfileRead)
+
+	assert.ElementsMatch(t, []string{rbac.PermUsersRead, rbac.PermProfileRead}, permissionsOf(t, app, key))
+
+	_, err := authCol.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"roles": []string{rbac.RoleUser}}})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{rbac.PermProfileRead}, permissionsOf(t, app, key))
+}
+
+func TestAPIKey_Expired(t *testing.T) {
+	app := setupAPIKeyApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+	key, apiKey := createAPIKey(t, app, tokens.Token, rbac.PermProfileRead)
+
+	_, err := apiKeyCol.UpdateOne(context.TODO(), bson.M{"_id": apiKey.ID}, bson.M{"$set": bson.M{"expiresAt": time.Now().Add(-time.Second)}})
+	require.NoError(t, err)
+
+	resp := sendJSON(t, app, http.MethodGet, "/test/permissions", key, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	app := setupAPIKeyApp()
+	user, password := createVerifiedUser(t)
+	other, otherPassword := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+	otherTokens := login(t, app, other.Email, otherPassword)
+	key, apiKey := createAPIKey(t, app, tokens.Token, rbac.PermProfileRead)
+
+	// Keys of other accounts can't be found
+	resp := sendJSON(t, app, http.MethodDelete, "/auth/api-keys/"+apiKey.ID.Hex(), otherTokens.Token, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp = sendJSON(t, app, http.MethodDelete, "/auth/api-keys/"+primitive.NewObjectID().Hex(), tokens.Token, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// A key can't revoke itself
+	resp = sendJSON(t, app, http.MethodDelete, "/auth/api-keys/"+apiKey.ID.Hex(), key, nil)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = sendJSON(t, app, http.MethodDelete, "/auth/api-keys/"+apiKey.ID.Hex(), tokens.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = sendJSON(t, app, http.MethodGet, "/test/permissions", key, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = sendJSON(t, app, http.MethodDelete, "/auth/api-keys/"+apiKey.ID.Hex(), tokens.Token, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestAPIKey_RevokedByPasswordReset(t *testing.T) {
+	app := setupAPIKeyApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+	key, _ := createAPIKey(t, app, tokens.Token, rbac.PermProfileRead)
+
+	token, err := newPasswordReset(context.TODO(), user)
+	require.NoError(t, err)
+	resp := resetPassword(t, app, token, randomPassword())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = sendJSON(t, app, http.MethodGet, "/test/permissions", key, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestAPIKey_IntrospectionIsNotAUse(t *testing.T) {
+	app := setupAPIKeyApp()
+	client, secret := createOAuthClient(t, false, rbac.PermTokensIntrospect)
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+	key, apiKey := createAPIKey(t, app, tokens.Token, rbac.PermProfileRead)
+
+	result := introspect(t, app, client, secret, key)
+	assert.Equal(t, true, result["active"])
+	assert.Equal(t, tokenTypeAPIKey, result["token_type"])
+	assert.Equal(t, rbac.PermProfileRead, result["scope"])
+
+	var stored models.APIKey
+	require.NoError(t, apiKeyCol.FindOne(context.TODO(), bson.M{"_id": apiKey.ID}).Decode(&stored))
+	assert.Nil(t, stored.LastUsedAt)
+}
+
+func TestAPIKey_RevokedOnlyByRevokeScope(t *testing.T) {
+	app := setupAPIKeyApp()
+	client, secret := createOAuthClient(t, false, rbac.PermTokensIntrospect)
+	revoker, revokerSecret := createOAuthClient(t, false, rbac.PermTokensRevoke)
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+	key, _ := createAPIKey(t, app, tokens.Token, rbac.PermProfileRead)
+
+	resp := postTokenForm(t, app, "/oauth/revoke", client, secret, key)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, true, introspect(t, app, client, secret, key)["active"])
+
+	resp = postTokenForm(t, app, "/oauth/revoke", revoker, revokerSecret, key)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, false, introspect(t, app, client, secret, key)["active"])
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
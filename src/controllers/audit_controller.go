@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/responses"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var auditLogCol = configs.GetCollection(configs.DB, "audit_logs")
+
+// Audited actions
+const (
+	auditRegister               = "register"
+	auditVerifyEmail            = "verify_email"
+	auditLogin                  = "login"
+	auditPasswordUpdate         = "password_update"
+	auditPasswordResetRequest   = "password_reset_request"
+	auditPasswordReset          = "password_reset"
+	auditEmailChangeRequest     = "email_change_request"
+	auditEmailChangeConfirm     = "email_change_confirm"
+	auditEmailChangeRevert      = "email_change_revert"
+	auditProfileUpdate          = "profile_update"
+	auditProfileDelete          = "profile_delete"
+	auditAccountDeletionRequest = "account_deletion_request"
+	auditSessionRevoke          = "session_revoke"
+	auditAPIKeyCreate           = "api_key_create"
+	auditAPIKeyRevoke           = "api_key_revoke"
+	auditTokenRevoke            = "token_revoke"
+
+	auditAdminAssignRoles       = "admin.assign_roles"
+	auditAdminUnlock            = "admin.unlock"
+	auditAdminVerify            = "admin.verify"
+	auditAdminResendOTP         = "admin.resend_otp"
+	auditAdminPasswordReset     = "admin.password_reset"
+	auditAdminDisable           = "admin.disable"
+	auditAdminEnable            = "admin.enable"
+	auditAdminRevokeSessions    = "admin.revoke_sessions"
+	auditAdminDeleteAllProfiles = "admin.delete_all_profiles"
+	auditAdminImpersonate       = "admin.impersonate"
+)
+
+const (
+	auditSuccess = "success"
+	auditFailure = "failure"
+)
+
+// recordAudit appends an entry to the audit log. Signed in requests are
+// attributed to the token's owner or machine client, or to the admin when
+// they impersonate the owner. Anything else is attributed to the target
+// itself. Failing to write the entry doesn't fail the request.
+func recordAudit(c *fiber.Ctx, action string, outcome string, targetID primitive.ObjectID, targetEmail string, details map[string]string) {
+	entry := models.AuditLog{
+		Action:      action,
+		Outcome:     outcome,
+		ActorID:     targetID,
+		ActorEmail:  targetEmail,
+		TargetID:    targetID,
+		TargetEmail: targetEmail,
+		IP:          c.IP(),
+		UserAgent:   c.Get(fiber.HeaderUserAgent),
+		RequestID:   responses.RequestID(c),
+		Details:     details,
+		CreatedAt:   time.Now(),
+	}
+	if actorID, err := realAuthID(c); err == nil {
+		entry.ActorID = actorID
+		entry.ActorEmail, _ = c.Locals("realEmail").(string)
+		entry.Impersonation, _ = c.Locals("impersonating").(bool)
+	} else if clientID, ok := c.Locals("clientId").(string); ok {
+		entry.ActorID = primitive.NilObjectID
+		entry.ActorEmail = ""
+		entry.ActorClientID = clientID
+	}
+
+	if _, err := auditLogCol.InsertOne(context.Background(), entry); err != nil {
+		log.Printf("🚨 Failed to write audit log entry %s for %s: %v", action, targetEmail, err)
+	}
+}
+
+// findAuditLogs returns a page of entries matching filter, newest first.
+func findAuditLogs(ctx context.Context, filter bson.M, page int64, limit int64) ([]models.AuditLog, int64, error) {
+	total, err := auditLogCol.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	cursor, err := auditLogCol.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		return nil, 0, err
+	}
+	entries := []models.AuditLog{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// GetAuditLogs lets admins query the audit log by action, outcome, actor,
+// target and time range.
+func GetAuditLogs(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	for _, param := range []string{"action", "outcome"} {
+		if value := c.Query(param); value != "" {
+			filter[param] = value
+		}
+	}
+	for _, param := range []string{"actorId", "targetId"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return responses.SendValidationError(c, map[string]string{param: "must be an account ID"})
+		}
+		filter[param] = id
+	}
+	createdAt := bson.M{}
+	for param, op := range map[string]string{"from": "$gte", "to": "$lt"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return responses.SendValidationError(c, map[string]string{param: "must be an RFC 3339 timestamp"})
+		}
+		createdAt[op] = t
+	}
+	if len(createdAt) > 0 {
+		filter["createdAt"] = createdAt
+	}
+	page, limit := pageParams(c)
+
+	entries, total, err := findAuditLogs(ctx, filter, page, limit)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to fetch audit logs", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "success", fiber.Map{
+		"data":  entries,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
+
+// madeByAdmin reports whether entry was made from another account than the
+// one it is about, which only admins can do. Entries made by machine clients
+// or without a signed in actor aren't.
+func madeByAdmin(entry models.AuditLog) bool {
+	return entry.ActorClientID == "" && !entry.ActorID.IsZero() && entry.ActorID != entry.TargetID
+}
+
+// GetSecurityActivity shows users what happened to their own account. Who
+// made admin changes is not shown, only that an admin did.
+func GetSecurityActivity(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	page, limit := pageParams(c)
+
+	entries, total, err := findAuditLogs(ctx, bson.M{"targetId": authID}, page, limit)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to fetch security activity", map[string]string{"error": err.Error()})
+	}
+
+	activity := make([]fiber.Map, 0, len(entries))
+	for _, entry := range entries {
+		activity = append(activity, fiber.Map{
+			"action":    entry.Action,
+			"outcome":   entry.Outcome,
+			"byAdmin":   madeByAdmin(entry),
+			"ip":        entry.IP,
+			"userAgent": entry.UserAgent,
+			"createdAt": entry.CreatedAt,
+		})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "success", fiber.Map{
+		"data":  activity,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
+	"strings"
 	"time"
 
 	"user-auth-profile-service/src/configs"
@@ -17,12 +19,14 @@ import (
 	"github.com/gofiber/fiber/v2"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"go.mongodb.org/mongo-driver/bson"
-	"golang.org/x/crypto/bcrypt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var (
 	authCol  = configs.GetCollection(configs.DB, "auth")
 	producer *rabbitmq.Producer
+	// eventProducer publishes account events for other services
+	eventProducer *rabbitmq.Producer
 	authValidate = validator.New()
 	config = configs.LoadEnv()
 )
@@ -52,6 +56,14 @@ func init() {
             log.Fatalf("Failed to create producer: %v", err)
         }
 
+        if _, err := ch.QueueDeclare(config.EventsQueueName, true, false, false, false, nil); err != nil {
+            log.Fatalf("Failed to declare events queue: %v", err)
+        }
+        eventProducer, err = rabbitmq.NewProducer(ch, config.EventsQueueName, true)
+        if err != nil {
+            log.Fatalf("Failed to create event producer: %v", err)
+        }
+
         log.Println("✅ Successfully connected to RabbitMQ")
         return
     }
@@ -73,19 +85,26 @@ func Register(c *fiber.Ctx) error {
 		}
 		return responses.SendValidationError(c, validationErrors)
 	}
+	if policyErrors := passwordPolicyErrors("Password", req.Password, req.Email); policyErrors != nil {
+		return responses.SendValidationError(c, policyErrors)
+	}
 
 	// Check if user already exists
 	count, _ := authCol.CountDocuments(context.TODO(), bson.M{"email": req.Email})
 	if count > 0 {
+		recordAudit(c, auditRegister, auditFailure, primitive.NilObjectID, req.Email, map[string]string{"reason": "email already registered"})
 		return responses.SendErrorResponse(c, fiber.StatusConflict, responses.ErrCodeDuplicate, "Email already registered", nil)
 	}
 
 	// Generate OTP
-	otp := fmt.Sprintf("%06d", time.Now().UnixNano()%1000000)
-	otpExpiry := time.Now().Add(15 * time.Minute)
+	otp, otpHash, err := newVerificationOTP()
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to generate OTP", map[string]string{"error": err.Error()})
+	}
+	otpExpiry := time.Now().Add(config.OTPTTL)
 
 	// Hash the password
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), 14)
+	hash, err := passwordHasher.Hash(req.Password)
 	if err != nil {
 		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to hash password", map[string]string{"error": err.Error()})
 	}
@@ -93,29 +112,21 @@ func Register(c *fiber.Ctx) error {
 	// Create user with unverified status
 	user := models.Auth{
 		Email:        req.Email,
-		Password:     string(hash),
-		OTP:         otp,
+		Password:     hash,
+		OTP:          otpHash,
 		OTPExpiresAt: otpExpiry,
+		OTPSentAt:    time.Now(),
 		IsVerified:   false,
 	}
 
-	_, err = authCol.InsertOne(context.TODO(), user)
+	result, err := authCol.InsertOne(context.TODO(), user)
 	if err != nil {
 		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to register user", map[string]string{"error": err.Error()})
 	}
 
 	// Send OTP via email using RabbitMQ
-	emailData := structure.EmailData{
-		To:       req.Email,
-		Subject:  "Verify Your Email",
-		Template: "email_verification",
-		Data: map[string]string{
-			"otp": otp,
-		},
-	}
-	
 	if producer != nil {
-		err = producer.Publish(context.Background(), emailData)
+		err = sendVerificationOTP(req.Email, otp)
 		if err != nil {
 			// If email fails, delete the user and return error
 			authCol.DeleteOne(context.TODO(), bson.M{"email": req.Email})
@@ -126,6 +137,8 @@ func Register(c *fiber.Ctx) error {
 		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Email service unavailable", nil)
 	}
 
+	authID, _ := result.InsertedID.(primitive.ObjectID)
+	recordAudit(c, auditRegister, auditSuccess, authID, req.Email, nil)
 	return responses.SendSuccessResponse(c, fiber.StatusCreated, "Registration initiated. Please check your email for OTP verification.", fiber.Map{
 		"email": req.Email,
 	})
@@ -160,16 +173,30 @@ func VerifyOTP(c *fiber.Ctx) error {
 		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Email already verified", nil)
 	}
 
-	// Verify OTP
-	if user.OTP != req.OTP {
-		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid OTP", nil)
-	}
-
 	// Check OTP expiration
-	if time.Now().After(user.OTPExpiresAt) {
+	if user.OTP == "" || time.Now().After(user.OTPExpiresAt) {
 		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "OTP has expired", nil)
 	}
 
+	// Count the attempt before checking the code, so parallel guesses can't
+	// get past the limit
+	result, err := authCol.UpdateOne(context.TODO(),
+		bson.M{"_id": user.ID, "otpAttempts": bson.M{"$not": bson.M{"$gte": config.OTPMaxAttempts}}},
+		bson.M{"$inc": bson.M{"otpAttempts": 1}},
+	)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to verify user", map[string]string{"error": err.Error()})
+	}
+	if result.ModifiedCount == 0 {
+		return responses.SendErrorResponse(c, fiber.StatusTooManyRequests, responses.ErrCodeTooManyRequests, "Too many attempts, please request a new OTP", nil)
+	}
+
+	// Verify OTP
+	if !checkPassword(user.OTP, req.OTP) {
+		recordAudit(c, auditVerifyEmail, auditFailure, user.ID, user.Email, map[string]string{"reason": "invalid otp"})
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid OTP", nil)
+	}
+
 	// Update user as verified
 	update := bson.M{
 		"$set": bson.M{
@@ -177,6 +204,7 @@ func VerifyOTP(c *fiber.Ctx) error {
 			"otp":          "",
 			"otpExpiresAt": time.Time{},
 		},
+		"$unset": bson.M{"otpAttempts": "", "otpSentAt": ""},
 	}
 
 	_, err = authCol.UpdateOne(context.TODO(), bson.M{"email": req.Email}, update)
@@ -184,40 +212,18 @@ func VerifyOTP(c *fiber.Ctx) error {
 		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to verify user", map[string]string{"error": err.Error()})
 	}
 
+	recordAudit(c, auditVerifyEmail, auditSuccess, user.ID, user.Email, nil)
 	return responses.SendSuccessResponse(c, fiber.StatusOK, "Email verified successfully", nil)
 }
 
-func Login(c *fiber.Ctx) error {
-	var data models.Auth
-	if err := c.BodyParser(&data); err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
-	}
-
-	var user models.Auth
-	err := authCol.FindOne(context.TODO(), bson.M{"email": data.Email}).Decode(&user)
-	if err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "User not found", nil)
-	}
-
-	// Check if user is verified
-	if !user.IsVerified {
-		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Email not verified", nil)
-	}
-
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(data.Password))
-	if err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid credentials", nil)
-	}
-
-	token, _ := utils.GenerateJWT(user.Email)
-	return responses.SendSuccessResponse(c, fiber.StatusOK, "Login successful", fiber.Map{"token": token})
-}
-
-func UpdatePassword(c *fiber.Ctx) error {
-	var req structure.UpdatePasswordRequest
+// ResendOTP sends a fresh verification code to an unverified account, for
+// users whose code expired or ran out of attempts.
+func ResendOTP(c *fiber.Ctx) error {
+	var req structure.ResendOTPRequest
 	if err := c.BodyParser(&req); err != nil {
 		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
 	}
+
 	if err := authValidate.Struct(req); err != nil {
 		validationErrors := make(map[string]string)
 		if ve, ok := err.(validator.ValidationErrors); ok {
@@ -228,165 +234,204 @@ func UpdatePassword(c *fiber.Ctx) error {
 		return responses.SendValidationError(c, validationErrors)
 	}
 
+	const sentMessage = "If the email is awaiting verification, a new OTP has been sent"
+
 	var user models.Auth
 	err := authCol.FindOne(context.TODO(), bson.M{"email": req.Email}).Decode(&user)
-	if err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "User not found", nil)
+	if err != nil || user.IsVerified {
+		return responses.SendSuccessResponse(c, fiber.StatusOK, sentMessage, nil)
 	}
 
-	// Check if user is verified
-	if !user.IsVerified {
-		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Email not verified", nil)
+	if wait := time.Until(user.OTPSentAt.Add(config.OTPResendCooldown)); wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		return responses.SendErrorResponse(c, fiber.StatusTooManyRequests, responses.ErrCodeTooManyRequests, "Please wait before requesting another OTP", nil)
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword))
+	if producer == nil {
+		log.Println("⚠️ RabbitMQ producer not initialized, skipping email notification")
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Email service unavailable", nil)
+	}
+
+	otp, otpHash, err := newVerificationOTP()
 	if err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Current password is incorrect", nil)
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to generate OTP", map[string]string{"error": err.Error()})
 	}
 
-	// Hash the new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), 14)
+	// Matching on the previous send time makes concurrent resends race for
+	// a single update instead of all sending emails
+	now := time.Now()
+	filter := bson.M{"_id": user.ID, "isVerified": false, "otpSentAt": user.OTPSentAt}
+	if user.OTPSentAt.IsZero() {
+		filter["otpSentAt"] = bson.M{"$exists": false}
+	}
+	result, err := authCol.UpdateOne(context.TODO(), filter,
+		bson.M{
+			"$set":   bson.M{"otp": otpHash, "otpExpiresAt": now.Add(config.OTPTTL), "otpSentAt": now},
+			"$unset": bson.M{"otpAttempts": ""},
+		},
+	)
 	if err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to hash new password", map[string]string{"error": err.Error()})
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to generate OTP", map[string]string{"error": err.Error()})
+	}
+	if result.ModifiedCount == 0 {
+		return responses.SendSuccessResponse(c, fiber.StatusOK, sentMessage, nil)
 	}
 
-	// Update password in DB
-	update := bson.M{"$set": bson.M{"password": string(hashedPassword)}}
-	_, err = authCol.UpdateOne(context.TODO(), bson.M{"email": req.Email}, update)
+	if err := sendVerificationOTP(user.Email, otp); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to send verification email", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, sentMessage, nil)
+}
+
+// newVerificationOTP returns a new email verification code and the hash
+// that is stored in its place.
+func newVerificationOTP() (string, string, error) {
+	otp, err := utils.GenerateOTP()
 	if err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to update password", map[string]string{"error": err.Error()})
+		return "", "", err
 	}
+	hash, err := passwordHasher.Hash(otp)
+	if err != nil {
+		return "", "", err
+	}
+	return otp, hash, nil
+}
 
-	return responses.SendSuccessResponse(c, fiber.StatusOK, "Password updated successfully", nil)
+func sendVerificationOTP(email string, otp string) error {
+	emailData := structure.EmailData{
+		To:       email,
+		Subject:  "Verify Your Email",
+		Template: "email_verification",
+		Data: map[string]string{
+			"otp":              otp,
+			"expiresInMinutes": fmt.Sprint(int(config.OTPTTL.Minutes())),
+		},
+	}
+	return producer.Publish(context.Background(), emailData)
 }
 
-func ForgotPassword(c *fiber.Ctx) error {
-	var req structure.ForgotPasswordRequest
-	if err := c.BodyParser(&req); err != nil {
+func Login(c *fiber.Ctx) error {
+	var data models.Auth
+	if err := c.BodyParser(&data); err != nil {
 		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
 	}
 
-	if err := authValidate.Struct(req); err != nil {
-		validationErrors := make(map[string]string)
-		if ve, ok := err.(validator.ValidationErrors); ok {
-			for _, e := range ve {
-				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
-			}
-		}
-		return responses.SendValidationError(c, validationErrors)
+	wait, locked, err := loginRetryAfter(context.TODO(), data.Email, c.IP())
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to check login attempts", map[string]string{"error": err.Error()})
+	}
+	if wait > 0 {
+		return rejectThrottledLogin(c, wait, locked)
 	}
 
-	// Check if user exists
 	var user models.Auth
-	err := authCol.FindOne(context.TODO(), bson.M{"email": req.Email}).Decode(&user)
+	err = authCol.FindOne(context.TODO(), bson.M{"email": data.Email}).Decode(&user)
 	if err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "User not found", nil)
+		recordLoginFailure(context.TODO(), data.Email, c.IP())
+		recordLoginEvent(c, primitive.NilObjectID, data.Email, loginMethodPassword, false, "unknown email")
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "User not found", nil)
 	}
 
-	// Generate token
-	rawToken := utils.GenerateResetToken()
-	hashedToken, err := bcrypt.GenerateFromPassword([]byte(rawToken), bcrypt.DefaultCost)
-	if err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to generate reset token", map[string]string{"error": err.Error()})
+	// Check if user is verified
+	if !user.IsVerified {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Email not verified", nil)
 	}
-	ExpiresAt := time.Now().Add(15 * time.Minute)
 
-	update := bson.M{"$set": bson.M{"token": string(hashedToken), "expiresAt": ExpiresAt}}
-	_, err = authCol.UpdateOne(context.TODO(), bson.M{"email": req.Email}, update)
-	if err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to save token", map[string]string{"error": err.Error()})
-	}
-	emailData := structure.EmailData{
-		To:       req.Email,
-		Subject:  "Reset Password Token",
-		Template: "reset_password",
-		Data: map[string]string{
-			"token": rawToken,
-		},
+	if !checkPassword(user.Password, data.Password) {
+		recordLoginFailure(context.TODO(), user.Email, c.IP())
+		recordLoginEvent(c, user.ID, user.Email, loginMethodPassword, false, "wrong password")
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid credentials", nil)
 	}
-	if producer != nil {
-		err = producer.Publish(context.Background(), emailData)
-		if err != nil {
-			// If email fails, clear the reset token fields in DB
-			resetUpdate := bson.M{
-				"$unset": bson.M{
-					"token":       "",
-					"expiresAt": "",
-				},
-			}
-			authCol.UpdateOne(context.TODO(), bson.M{"email": req.Email}, resetUpdate)
-			return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to send verification email", map[string]string{"error": err.Error()})
-		}
-	} else {
-		// If producer is not initialized, clear the reset token fields in DB
-		resetUpdate := bson.M{
-			"$unset": bson.M{
-				"token":       "",
-				"expiresAt": "",
-			},
-		}
-		authCol.UpdateOne(context.TODO(), bson.M{"email": req.Email}, resetUpdate)
-		log.Println("⚠️ RabbitMQ producer not initialized, skipping email notification")
-		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Email service unavailable", nil)
+	// With two-factor enabled the counter is only cleared once the second
+	// factor is checked, so fresh challenges don't reset it
+	if !user.MFAEnabled {
+		clearLoginFailures(context.TODO(), user.Email)
 	}
+	upgradePasswordHash(context.TODO(), user, data.Password)
 
-	return responses.SendSuccessResponse(c, fiber.StatusOK, "Reset Token sent to your email", nil)
+	return completeLogin(context.TODO(), c, user, loginMethodPassword)
 }
 
-func ResetPassword(c *fiber.Ctx) error {
+func UpdatePassword(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Get email, token, password from request body
-	var req structure.ResetRequest
+	var req structure.UpdatePasswordRequest
 	if err := c.BodyParser(&req); err != nil {
 		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
 	}
-
-	if req.Password != req.ConfirmPassword {
-		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Passwords do not match", nil)
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
 	}
 
-	if req.Email == "" || req.Token == "" {
-		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Email and token are required", nil)
+	// The account is the signed in one. The email in the body is only
+	// accepted for older clients and must match it.
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "User not found", nil)
+	}
+	if req.Email != "" && !strings.EqualFold(req.Email, user.Email) {
+		return responses.SendErrorResponse(c, fiber.StatusForbidden, responses.ErrCodeForbidden, "Email does not match the signed in account", nil)
 	}
 
-	// Find user by email
-	var user models.Auth
-	err := authCol.FindOne(ctx, bson.M{"email": req.Email}).Decode(&user)
+	wait, locked, err := loginRetryAfter(ctx, user.Email, c.IP())
 	if err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid or expired reset token", nil)
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to check login attempts", map[string]string{"error": err.Error()})
+	}
+	if wait > 0 {
+		return rejectThrottledLogin(c, wait, locked)
 	}
 
-	// Check if reset token exists and is not expired
-	if user.Token == "" || time.Now().After(user.ExpiresAt) {
-		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Reset token is invalid or expired", nil)
+	// Check if user is verified
+	if !user.IsVerified {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Email not verified", nil)
 	}
 
-	// Compare the provided token with the hashed token in DB
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Token), []byte(req.Token)); err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Reset token is invalid", nil)
+	if !checkPassword(user.Password, req.CurrentPassword) {
+		recordLoginFailure(ctx, user.Email, c.IP())
+		recordAudit(c, auditPasswordUpdate, auditFailure, user.ID, user.Email, map[string]string{"reason": "wrong current password"})
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Current password is incorrect", nil)
 	}
+	clearLoginFailures(ctx, user.Email)
 
-	// Hash the new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to hash password", map[string]string{"error": err.Error()})
+	if policyErrors := passwordPolicyErrors("NewPassword", req.NewPassword, user.Email); policyErrors != nil {
+		return responses.SendValidationError(c, policyErrors)
+	}
+	if reusesPassword(user, req.NewPassword) {
+		return responses.SendValidationError(c, map[string]string{"NewPassword": "Password was used recently, choose a different one"})
 	}
 
-	// Update password and clear the reset token fields
-	update := bson.M{
-		"$set": bson.M{"password": string(hashedPassword)},
-		"$unset": bson.M{
-			"token":       "",
-			"expiresAt": "",
-		},
+	// Hash the new password
+	hashedPassword, err := passwordHasher.Hash(req.NewPassword)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to hash new password", map[string]string{"error": err.Error()})
 	}
 
-	_, err = authCol.UpdateOne(ctx, bson.M{"email": req.Email}, update)
+	// Update password in DB
+	update := bson.M{"$set": bson.M{"password": hashedPassword, "passwordHistory": nextPasswordHistory(user)}}
+	_, err = authCol.UpdateOne(ctx, bson.M{"_id": user.ID}, update)
 	if err != nil {
 		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to update password", map[string]string{"error": err.Error()})
 	}
 
-	return responses.SendSuccessResponse(c, fiber.StatusOK, "Password has been reset successfully", nil)
+	// Other devices signed in with the old password are signed out, the
+	// one that changed it stays signed in
+	sid, _ := currentClaims(c)["sid"].(string)
+	if err := endOtherSessions(ctx, user.ID, sid); err != nil {
+		log.Printf("⚠️ Failed to revoke other sessions after password update for %s: %v", user.Email, err)
+	}
+
+	recordAudit(c, auditPasswordUpdate, auditSuccess, user.ID, user.Email, nil)
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Password updated successfully", nil)
 }
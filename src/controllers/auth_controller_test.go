@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
@@ -12,12 +13,17 @@ import (
 	"strconv"
 	"testing"
 
+	"user-auth-profile-service/src/middleware"
 	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/rbac"
 	"user-auth-profile-service/src/responses"
 	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
@@ -30,10 +36,46 @@ func setupApp() *fiber.App {
 	return app
 }
 
+// setupAuthApp registers the session, token and account routes the way
+// routes.AuthRoute and routes.OIDCRoute do, without the rate limits.
+func setupAuthApp() *fiber.App {
+	app := setupApp()
+	app.Post("/auth/refresh", RefreshToken)
+	app.Post("/auth/logout", middleware.AuthMiddleware, Logout)
+	app.Post("/auth/logout-all", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, LogoutAll)
+	app.Get("/auth/sessions", middleware.AuthMiddleware, middleware.BlockAPIKeys, ListSessions)
+	app.Delete("/auth/sessions/:id", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, DeleteSession)
+	app.Patch("/auth/update-password", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, UpdatePassword)
+	app.Post("/auth/api-keys", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, CreateAPIKey)
+	app.Get("/auth/api-keys", middleware.AuthMiddleware, ListAPIKeys)
+	app.Delete("/auth/api-keys/:id", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, RevokeAPIKey)
+	app.Post("/auth/reset-password", ResetPassword)
+	app.Post("/auth/change-email/revert", RevertEmailChange)
+	app.Post("/auth/delete-account", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, DeleteAccount)
+	app.Post("/authorize", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, ApproveAuthorization)
+	app.Post("/oauth/introspect", Introspect)
+	app.Post("/oauth/revoke", Revoke)
+	app.Post("/admin/impersonate/:authId", middleware.AuthMiddleware, middleware.RequireRole(rbac.RoleAdmin), middleware.RequirePermission(rbac.PermUsersImpersonate), Impersonate)
+	return app
+}
+
 func initTestDB(db *mongo.Database) {
 	// Override the authCol with our test collection
 	authCol = db.Collection("auth")
 	testAuthCol = authCol
+	refreshTokenCol = db.Collection("refresh_tokens")
+	webauthnSessionCol = db.Collection("webauthn_sessions")
+	oauthStateCol = db.Collection("oauth_states")
+	oauthClientCol = db.Collection("oauth_clients")
+	authCodeCol = db.Collection("oauth_codes")
+	loginAttemptCol = db.Collection("login_attempts")
+	passwordResetCol = db.Collection("password_resets")
+	emailChangeCol = db.Collection("email_changes")
+	loginHistoryCol = db.Collection("login_history")
+	auditLogCol = db.Collection("audit_logs")
+	sessionCol = db.Collection("sessions")
+	apiKeyCol = db.Collection("api_keys")
+	utils.UseTestDB(db)
 }
 
 func TestMain(m *testing.M) {
@@ -174,3 +216,67 @@ func getUserByEmail(email string) (*models.Auth, error) {
 	}
 	return user, nil
 }
+
+// createVerifiedUser stores a verified account with the given roles and
+// returns it together with its password.
+func createVerifiedUser(t *testing.T, roles ...string) (models.Auth, string) {
+	password := randomPassword()
+	hash, err := passwordHasher.Hash(password)
+	require.NoError(t, err)
+	user := models.Auth{
+		ID:         primitive.NewObjectID(),
+		Email:      randomEmail(),
+		Password:   hash,
+		IsVerified: true,
+		Roles:      roles,
+	}
+	_, err = authCol.InsertOne(context.TODO(), user)
+	require.NoError(t, err)
+	return user, password
+}
+
+type tokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// sendJSON sends payload as JSON, authenticated with token unless it's empty.
+func sendJSON(t *testing.T, app *fiber.App, method string, path string, token string, payload interface{}) *http.Response {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		require.NoError(t, err)
+		body = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, body)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	return resp
+}
+
+// decodeData decodes the data of a successful response into v.
+func decodeData(t *testing.T, resp *http.Response, v interface{}) {
+	var res struct {
+		Data json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	require.NoError(t, json.Unmarshal(res.Data, v))
+}
+
+func login(t *testing.T, app *fiber.App, email string, password string) tokenPair {
+	resp := sendJSON(t, app, http.MethodPost, "/auth/login", "", models.Auth{Email: email, Password: password})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var tokens tokenPair
+	decodeData(t, resp, &tokens)
+	require.NotEmpty(t, tokens.Token)
+	require.NotEmpty(t, tokens.RefreshToken)
+	return tokens
+}
+
+func refresh(t *testing.T, app *fiber.App, refreshToken string) *http.Response {
+	return sendJSON(t, app, http.MethodPost, "/auth/refresh", "", structure.RefreshTokenRequest{RefreshToken: refreshToken})
+}
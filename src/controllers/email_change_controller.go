@@ -0,0 +1,331 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var emailChangeCol = configs.GetCollection(configs.DB, "email_changes")
+
+// errEmailChangeStale means the account or the change moved on since the
+// link was sent, e.g. the same link was used twice.
+var errEmailChangeStale = errors.New("email change is no longer valid")
+
+// switchAccountEmail moves an account from one email to another and records
+// the new state of the change. The auth and profile documents are updated in
+// one transaction so they never disagree about the email.
+func switchAccountEmail(ctx context.Context, change models.EmailChange, from string, to string, set bson.M) error {
+	session, err := authCol.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		result, err := authCol.UpdateOne(sc, bson.M{"_id": change.AuthID, "email": from}, bson.M{"$set": bson.M{"email": to}})
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return nil, errEmailChangeStale
+		}
+
+		// Not every account has a profile yet, and profiles created before
+		// they were linked to their account are only found by email
+		profileFilter := bson.M{"$or": []bson.M{
+			{"authId": change.AuthID},
+			{"email": from, "authId": bson.M{"$exists": false}},
+		}}
+		if _, err := userCollection.UpdateOne(sc, profileFilter, bson.M{"$set": bson.M{"email": to}}); err != nil {
+			return nil, err
+		}
+
+		result, err = emailChangeCol.UpdateOne(sc, bson.M{"_id": change.ID, "status": change.Status}, bson.M{"$set": set})
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return nil, errEmailChangeStale
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// cancelPendingEmailChanges drops any change still waiting for confirmation.
+func cancelPendingEmailChanges(ctx context.Context, authID primitive.ObjectID) error {
+	_, err := emailChangeCol.UpdateMany(ctx,
+		bson.M{"authId": authID, "status": models.EmailChangePending},
+		bson.M{"$set": bson.M{"status": models.EmailChangeCancelled}},
+	)
+	return err
+}
+
+// RequestEmailChange sends a confirmation link to the new address and a
+// notice with a revert link to the current one. Nothing changes until the
+// new address confirms.
+func RequestEmailChange(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.ChangeEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "User not found", nil)
+	}
+
+	wait, locked, err := loginRetryAfter(ctx, user.Email, c.IP())
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to check login attempts", map[string]string{"error": err.Error()})
+	}
+	if wait > 0 {
+		return rejectThrottledLogin(c, wait, locked)
+	}
+	if !checkPassword(user.Password, req.Password) {
+		recordLoginFailure(ctx, user.Email, c.IP())
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Password is incorrect", nil)
+	}
+	clearLoginFailures(ctx, user.Email)
+
+	if strings.EqualFold(req.NewEmail, user.Email) {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "New email is the same as the current one", nil)
+	}
+	count, err := authCol.CountDocuments(ctx, bson.M{"email": req.NewEmail})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to check email", map[string]string{"error": err.Error()})
+	}
+	if count > 0 {
+		return responses.SendErrorResponse(c, fiber.StatusConflict, responses.ErrCodeDuplicate, "Email already registered", nil)
+	}
+
+	if producer == nil {
+		log.Println("⚠️ RabbitMQ producer not initialized, skipping email notification")
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Email service unavailable", nil)
+	}
+
+	confirmToken, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to create email change", map[string]string{"error": err.Error()})
+	}
+	revertToken, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to create email change", map[string]string{"error": err.Error()})
+	}
+
+	if err := cancelPendingEmailChanges(ctx, user.ID); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to create email change", map[string]string{"error": err.Error()})
+	}
+	now := time.Now()
+	change := models.EmailChange{
+		AuthID:           user.ID,
+		OldEmail:         user.Email,
+		NewEmail:         req.NewEmail,
+		Status:           models.EmailChangePending,
+		ConfirmTokenHash: utils.HashToken(confirmToken),
+		RevertTokenHash:  utils.HashToken(revertToken),
+		CreatedAt:        now,
+		ConfirmExpiresAt: now.Add(config.EmailChangeTTL),
+		ExpiresAt:        now.Add(config.EmailChangeRevertTTL),
+	}
+	result, err := emailChangeCol.InsertOne(ctx, change)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to create email change", map[string]string{"error": err.Error()})
+	}
+
+	confirmLink := config.EmailChangeURL + "?token=" + url.QueryEscape(confirmToken)
+	revertLink := config.EmailChangeRevertURL + "?token=" + url.QueryEscape(revertToken)
+	emails := []structure.EmailData{
+		{
+			To:       req.NewEmail,
+			Subject:  "Confirm your new email address",
+			Template: "confirm_email_change",
+			Link:     confirmLink,
+			Data: map[string]string{
+				"link":             confirmLink,
+				"expiresInMinutes": fmt.Sprint(int(config.EmailChangeTTL.Minutes())),
+			},
+		},
+		{
+			To:       user.Email,
+			Subject:  "Your email address is being changed",
+			Template: "email_change_requested",
+			Link:     revertLink,
+			Data: map[string]string{
+				"newEmail":   req.NewEmail,
+				"revertLink": revertLink,
+				"ip":         c.IP(),
+			},
+		},
+	}
+	for _, emailData := range emails {
+		if err := producer.Publish(context.Background(), emailData); err != nil {
+			// Without the notice the owner couldn't revert, so don't allow the change at all
+			emailChangeCol.DeleteOne(ctx, bson.M{"_id": result.InsertedID})
+			return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to send confirmation email", map[string]string{"error": err.Error()})
+		}
+	}
+
+	recordAudit(c, auditEmailChangeRequest, auditSuccess, user.ID, user.Email, map[string]string{"newEmail": req.NewEmail})
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Confirmation link sent to the new email address", fiber.Map{
+		"newEmail":  req.NewEmail,
+		"expiresAt": change.ConfirmExpiresAt,
+	})
+}
+
+// ConfirmEmailChange switches the account to the new email once the link
+// sent there is used.
+func ConfirmEmailChange(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.EmailChangeTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	var change models.EmailChange
+	err := emailChangeCol.FindOne(ctx, bson.M{
+		"confirmTokenHash": utils.HashToken(req.Token),
+		"status":           models.EmailChangePending,
+		"confirmExpiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&change)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Confirmation link is invalid or expired", nil)
+	}
+
+	err = switchAccountEmail(ctx, change, change.OldEmail, change.NewEmail, bson.M{
+		"status":      models.EmailChangeConfirmed,
+		"confirmedAt": time.Now(),
+	})
+	if errors.Is(err, errEmailChangeStale) {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Confirmation link is invalid or expired", nil)
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return responses.SendErrorResponse(c, fiber.StatusConflict, responses.ErrCodeDuplicate, "Email already registered", nil)
+	}
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to change email", map[string]string{"error": err.Error()})
+	}
+
+	// Access tokens carry the old email. Refreshing picks up the new one, so
+	// only the access tokens need to go.
+	if err := utils.RevokeAllAccessTokens(ctx, change.AuthID); err != nil {
+		log.Printf("⚠️ Failed to revoke access tokens of %s after email change: %v", change.AuthID.Hex(), err)
+	}
+
+	log.Printf("✅ Account %s changed email from %s to %s", change.AuthID.Hex(), change.OldEmail, change.NewEmail)
+	recordAudit(c, auditEmailChangeConfirm, auditSuccess, change.AuthID, change.NewEmail, map[string]string{"oldEmail": change.OldEmail})
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Email address updated", fiber.Map{"email": change.NewEmail})
+}
+
+// RevertEmailChange lets the old address undo a change, or cancel it if it
+// hasn't been confirmed yet. Reverting signs the account out everywhere in
+// case the change was made by someone else.
+func RevertEmailChange(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.EmailChangeTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	var change models.EmailChange
+	err := emailChangeCol.FindOne(ctx, bson.M{
+		"revertTokenHash": utils.HashToken(req.Token),
+		"status":          bson.M{"$in": []string{models.EmailChangePending, models.EmailChangeConfirmed}},
+		"expiresAt":       bson.M{"$gt": time.Now()},
+	}).Decode(&change)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Revert link is invalid or expired", nil)
+	}
+
+	if change.Status == models.EmailChangePending {
+		if err := cancelPendingEmailChanges(ctx, change.AuthID); err != nil {
+			return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to cancel email change", map[string]string{"error": err.Error()})
+		}
+		recordAudit(c, auditEmailChangeRevert, auditSuccess, change.AuthID, change.OldEmail, map[string]string{"newEmail": change.NewEmail, "status": models.EmailChangePending})
+		return responses.SendSuccessResponse(c, fiber.StatusOK, "Email change cancelled", nil)
+	}
+
+	err = switchAccountEmail(ctx, change, change.NewEmail, change.OldEmail, bson.M{
+		"status":     models.EmailChangeReverted,
+		"revertedAt": time.Now(),
+	})
+	if errors.Is(err, errEmailChangeStale) {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Revert link is invalid or expired", nil)
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return responses.SendErrorResponse(c, fiber.StatusConflict, responses.ErrCodeDuplicate, "The old email is now used by another account", nil)
+	}
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to revert email change", map[string]string{"error": err.Error()})
+	}
+
+	// Whoever changed the email may have started another change or still be signed in
+	if err := cancelPendingEmailChanges(ctx, change.AuthID); err != nil {
+		log.Printf("⚠️ Failed to cancel pending email changes for %s: %v", change.AuthID.Hex(), err)
+	}
+	if err := revokeAllTokens(ctx, change.AuthID); err != nil {
+		log.Printf("⚠️ Failed to revoke sessions after email revert for %s: %v", change.AuthID.Hex(), err)
+	}
+	if err := revokeAllAPIKeys(ctx, change.AuthID); err != nil {
+		log.Printf("⚠️ Failed to revoke API keys after email revert for %s: %v", change.AuthID.Hex(), err)
+	}
+
+	log.Printf("🚨 Account %s reverted email change from %s back to %s", change.AuthID.Hex(), change.NewEmail, change.OldEmail)
+	recordAudit(c, auditEmailChangeRevert, auditSuccess, change.AuthID, change.OldEmail, map[string]string{"newEmail": change.NewEmail, "status": models.EmailChangeConfirmed})
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Email change reverted, reset your password if you didn't make this change", fiber.Map{"email": change.OldEmail})
+}
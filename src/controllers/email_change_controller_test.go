@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// createEmailChange stores a pending change of user's email and returns it
+// with its confirm and revert tokens.
+func createEmailChange(t *testing.T, user models.Auth) (models.EmailChange, string, string) {
+	confirmToken, err := utils.GenerateOpaqueToken(32)
+	require.NoError(t, err)
+	revertToken, err := utils.GenerateOpaqueToken(32)
+	require.NoError(t, err)
+
+	now := time.Now()
+	change := models.EmailChange{
+		ID:               primitive.NewObjectID(),
+		AuthID:           user.ID,
+		OldEmail:         user.Email,
+		NewEmail:         randomEmail(),
+		Status:           models.EmailChangePending,
+		ConfirmTokenHash: utils.HashToken(confirmToken),
+		RevertTokenHash:  utils.HashToken(revertToken),
+		CreatedAt:        now,
+		ConfirmExpiresAt: now.Add(time.Hour),
+		ExpiresAt:        now.Add(24 * time.Hour),
+	}
+	_, err = emailChangeCol.InsertOne(context.TODO(), change)
+	require.NoError(t, err)
+	return change, confirmToken, revertToken
+}
+
+func TestRevertEmailChange_CancelsPendingChange(t *testing.T) {
+	app := setupAuthApp()
+	user, _ := createVerifiedUser(t)
+	change, _, revertToken := createEmailChange(t, user)
+
+	resp := sendJSON(t, app, http.MethodPost, "/auth/change-email/revert", "", structure.EmailChangeTokenRequest{Token: revertToken})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var stored models.EmailChange
+	require.NoError(t, emailChangeCol.FindOne(context.TODO(), bson.M{"_id": change.ID}).Decode(&stored))
+	assert.Equal(t, models.EmailChangeCancelled, stored.Status)
+
+	account, err := findAuthByID(context.TODO(), user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.Email, account.Email)
+
+	// The link can't be used again
+	resp = sendJSON(t, app, http.MethodPost, "/auth/change-email/revert", "", structure.EmailChangeTokenRequest{Token: revertToken})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestRevertEmailChange_ConfirmTokenIsNotARevertToken(t *testing.T) {
+	app := setupAuthApp()
+	user, _ := createVerifiedUser(t)
+	_, confirmToken, _ := createEmailChange(t, user)
+
+	resp := sendJSON(t, app, http.MethodPost, "/auth/change-email/revert", "", structure.EmailChangeTokenRequest{Token: confirmToken})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
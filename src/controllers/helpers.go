@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"errors"
+
+	"user-auth-profile-service/src/rbac"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// currentAuthID returns the ID of the auth account behind the request, as set
+// by middleware.AuthMiddleware.
+func currentAuthID(c *fiber.Ctx) (primitive.ObjectID, error) {
+	sub, ok := c.Locals("authId").(string)
+	if !ok || sub == "" {
+		return primitive.NilObjectID, errors.New("missing account id in token")
+	}
+	return primitive.ObjectIDFromHex(sub)
+}
+
+// realAuthID returns the ID of the account that sent the request. It is the
+// admin's when they impersonate another account, currentAuthID otherwise.
+func realAuthID(c *fiber.Ctx) (primitive.ObjectID, error) {
+	sub, ok := c.Locals("realAuthId").(string)
+	if !ok || sub == "" {
+		return primitive.NilObjectID, errors.New("missing account id in token")
+	}
+	return primitive.ObjectIDFromHex(sub)
+}
+
+// currentClaims returns the verified claims of the request's access token.
+func currentClaims(c *fiber.Ctx) jwt.MapClaims {
+	claims, _ := c.Locals("claims").(jwt.MapClaims)
+	return claims
+}
+
+// hasPermission reports whether the request's access token grants perm.
+func hasPermission(c *fiber.Ctx, perm string) bool {
+	return rbac.Has(utils.ClaimStrings(currentClaims(c), "permissions"), perm)
+}
+
+// pageParams reads the page (from 1) and limit query parameters of a list
+// endpoint. The limit defaults to 20 and is capped at 100.
+func pageParams(c *fiber.Ctx) (page int64, limit int64) {
+	page = int64(c.QueryInt("page", 1))
+	if page < 1 {
+		page = 1
+	}
+	limit = int64(c.QueryInt("limit", 20))
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return page, limit
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/rbac"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Values of token_type in introspection responses
+const (
+	tokenTypeAccess  = "access_token"
+	tokenTypeRefresh = "refresh_token"
+	tokenTypeAPIKey  = "api_key"
+)
+
+// authenticateTokenClient authenticates the client calling the introspection
+// or revocation endpoint. The OAuth error has already been written when it
+// returns an error.
+func authenticateTokenClient(ctx context.Context, c *fiber.Ctx, req structure.IntrospectionRequest) (models.OAuthClient, error) {
+	clientID, secret := clientCredentials(c, req.ClientID, req.ClientSecret)
+	client, err := authenticateClient(ctx, clientID, secret)
+	if err != nil {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="token"`)
+		return client, oauthError(c, fiber.StatusUnauthorized, "invalid_client", err.Error())
+	}
+	return client, nil
+}
+
+// clientMay reports whether client is a machine client granted scope.
+func clientMay(client models.OAuthClient, scope string) bool {
+	return client.Machine && containsString(client.Scopes, scope)
+}
+
+// findActiveRefreshToken returns the stored refresh token if it can still be
+// redeemed.
+func findActiveRefreshToken(ctx context.Context, token string) (models.RefreshToken, error) {
+	var stored models.RefreshToken
+	err := refreshTokenCol.FindOne(ctx, bson.M{
+		"tokenHash": utils.HashToken(token),
+		"rotatedAt": bson.M{"$exists": false},
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&stored)
+	return stored, err
+}
+
+// introspectToken describes an active token in RFC 7662 terms. ok is false
+// for tokens that are unknown, expired or revoked.
+func introspectToken(ctx context.Context, token string) (fiber.Map, bool, error) {
+	if strings.HasPrefix(token, utils.APIKeyPrefix) {
+		claims, err := utils.LookupAPIKey(ctx, token)
+		if errors.Is(err, utils.ErrInvalidAPIKey) {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return introspectionClaims(claims, tokenTypeAPIKey), true, nil
+	}
+
+	if claims, err := utils.ParseTypedJWT(token, utils.TokenUseAccess, utils.TokenUseClientAccess); err == nil {
+		revoked, err := utils.IsAccessTokenRevoked(ctx, claims)
+		if err != nil {
+			return nil, false, err
+		}
+		if revoked {
+			return nil, false, nil
+		}
+		return introspectionClaims(claims, tokenTypeAccess), true, nil
+	}
+
+	stored, err := findActiveRefreshToken(ctx, token)
+	if err != nil {
+		return nil, false, nil
+	}
+	return fiber.Map{
+		"active":     true,
+		"token_type": tokenTypeRefresh,
+		"sub":        stored.AuthID.Hex(),
+		"sid":        stored.FamilyID,
+		"iat":        stored.CreatedAt.Unix(),
+		"exp":        stored.ExpiresAt.Unix(),
+		"iss":        config.JWTIssuer,
+	}, true, nil
+}
+
+// introspectionClaims turns the claims of an access token or API key into an
+// introspection response. Our own access tokens have no scope claim, their
+// permissions are reported as the scope instead.
+func introspectionClaims(claims jwt.MapClaims, tokenType string) fiber.Map {
+	result := fiber.Map{}
+	for name, value := range claims {
+		result[name] = value
+	}
+	result["active"] = true
+	result["token_type"] = tokenType
+	if email, ok := claims["email"].(string); ok {
+		result["username"] = email
+	}
+	if _, ok := claims["scope"]; !ok {
+		result["scope"] = strings.Join(utils.ClaimStrings(claims, "permissions"), " ")
+	}
+	return result
+}
+
+// Introspect is the RFC 7662 token introspection endpoint, for components
+// that can't validate our tokens themselves. Only machine clients granted
+// tokens:introspect may call it, the response tells whose token it is and what
+// it may do.
+func Introspect(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	var req structure.IntrospectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_request", "malformed request body")
+	}
+	client, err := authenticateTokenClient(ctx, c, req)
+	if err != nil {
+		return err
+	}
+	if !clientMay(client, rbac.PermTokensIntrospect) {
+		return oauthError(c, fiber.StatusForbidden, "unauthorized_client", "client is not allowed to introspect tokens")
+	}
+	if req.Token == "" {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_request", "token is required")
+	}
+
+	result, active, err := introspectToken(ctx, req.Token)
+	if err != nil {
+		return oauthError(c, fiber.StatusInternalServerError, "server_error", "failed to introspect token")
+	}
+	if !active {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"active": false})
+	}
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// Revoke is the RFC 7009 token revocation endpoint. Clients may revoke the
+// access tokens issued to them. Other tokens, API keys and refresh tokens can
+// only be revoked by machine clients granted tokens:revoke. Unknown and
+// already invalid tokens are not an error, and neither are tokens the client
+// may not revoke, so the endpoint can't be used to test tokens.
+func Revoke(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.IntrospectionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_request", "malformed request body")
+	}
+	client, err := authenticateTokenClient(ctx, c, req)
+	if err != nil {
+		return err
+	}
+	if req.Token == "" {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_request", "token is required")
+	}
+	// Revocations are audited as made by the client
+	c.Locals("clientId", client.ClientID)
+	mayRevokeAny := clientMay(client, rbac.PermTokensRevoke)
+
+	if strings.HasPrefix(req.Token, utils.APIKeyPrefix) {
+		if !mayRevokeAny {
+			return c.SendStatus(fiber.StatusOK)
+		}
+		var apiKey models.APIKey
+		if err := apiKeyCol.FindOneAndUpdate(ctx,
+			bson.M{"keyHash": utils.HashToken(req.Token), "revokedAt": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+		).Decode(&apiKey); err == nil {
+			recordAudit(c, auditTokenRevoke, auditSuccess, apiKey.AuthID, "", map[string]string{"tokenType": tokenTypeAPIKey, "keyId": apiKey.ID.Hex()})
+		}
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	if claims, err := utils.ParseTypedJWT(req.Token, utils.TokenUseAccess, utils.TokenUseClientAccess); err == nil {
+		if tokenClient, _ := claims["client_id"].(string); !mayRevokeAny && tokenClient != client.ClientID {
+			return c.SendStatus(fiber.StatusOK)
+		}
+		jti, _ := claims["jti"].(string)
+		expiresAt, _ := claims.GetExpirationTime()
+		if expiresAt == nil {
+			return c.SendStatus(fiber.StatusOK)
+		}
+		// Machine clients have no account, their tokens are revoked by JTI alone
+		sub, _ := claims.GetSubject()
+		authID, _ := primitive.ObjectIDFromHex(sub)
+		if err := utils.RevokeAccessToken(ctx, jti, authID, expiresAt.Time); err != nil {
+			log.Printf("❌ Failed to revoke access token %s: %v", jti, err)
+			return oauthError(c, fiber.StatusServiceUnavailable, "temporarily_unavailable", "failed to revoke token")
+		}
+		recordAudit(c, auditTokenRevoke, auditSuccess, authID, "", map[string]string{"tokenType": tokenTypeAccess, "jti": jti})
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	if !mayRevokeAny {
+		return c.SendStatus(fiber.StatusOK)
+	}
+	stored, err := findActiveRefreshToken(ctx, req.Token)
+	if err != nil {
+		return c.SendStatus(fiber.StatusOK)
+	}
+	// Revoking a refresh token ends its whole session, including the access
+	// tokens issued with it
+	if err := endSession(ctx, stored.AuthID, stored.FamilyID); err != nil {
+		log.Printf("❌ Failed to revoke session %s: %v", stored.FamilyID, err)
+		return oauthError(c, fiber.StatusServiceUnavailable, "temporarily_unavailable", "failed to revoke token")
+	}
+	recordAudit(c, auditTokenRevoke, auditSuccess, stored.AuthID, "", map[string]string{"tokenType": tokenTypeRefresh, "sessionId": stored.FamilyID})
+	return c.SendStatus(fiber.StatusOK)
+}
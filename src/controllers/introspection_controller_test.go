@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/rbac"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// createOAuthClient registers a client for the token endpoints and returns
+// its secret, which is empty for public clients. Clients given scopes are
+// machine clients.
+func createOAuthClient(t *testing.T, public bool, scopes ...string) (models.OAuthClient, string) {
+	client := models.OAuthClient{
+		ID:        primitive.NewObjectID(),
+		ClientID:  "client-" + uuid.New().String(),
+		Name:      "Test client",
+		Scopes:    scopes,
+		Public:    public,
+		Machine:   len(scopes) > 0,
+		CreatedAt: time.Now(),
+	}
+	secret := ""
+	if !public {
+		var err error
+		secret, err = utils.GenerateOpaqueToken(32)
+		require.NoError(t, err)
+		client.SecretHash = utils.HashToken(secret)
+	}
+	_, err := oauthClientCol.InsertOne(context.TODO(), client)
+	require.NoError(t, err)
+	return client, secret
+}
+
+// postTokenForm calls the introspection or revocation endpoint as client.
+func postTokenForm(t *testing.T, app *fiber.App, path string, client models.OAuthClient, secret string, token string) *http.Response {
+	form := url.Values{"token": {token}, "client_id": {client.ClientID}}
+	if secret != "" {
+		form.Set("client_secret", secret)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := app.Test(req, -1)
+	require.NoError(t, err)
+	return resp
+}
+
+func introspect(t *testing.T, app *fiber.App, client models.OAuthClient, secret string, token string) map[string]interface{} {
+	resp := postTokenForm(t, app, "/oauth/introspect", client, secret, token)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var result map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	return result
+}
+
+func TestIntrospect_ActiveTokens(t *testing.T) {
+	app := setupAuthApp()
+	client, secret := createOAuthClient(t, false, rbac.PermTokensIntrospect)
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	result := introspect(t, app, client, secret, tokens.Token)
+	assert.Equal(t, true, result["active"])
+	assert.Equal(t, tokenTypeAccess, result["token_type"])
+	assert.Equal(t, user.ID.Hex(), result["sub"])
+	assert.Equal(t, user.Email, result["username"])
+
+	result = introspect(t, app, client, secret, tokens.RefreshToken)
+	assert.Equal(t, true, result["active"])
+	assert.Equal(t, tokenTypeRefresh, result["token_type"])
+	assert.Equal(t, user.ID.Hex(), result["sub"])
+
+	result = introspect(t, app, client, secret, "garbage")
+	assert.Equal(t, map[string]interface{}{"active": false}, result)
+}
+
+func TestIntrospect_RequiresIntrospectScope(t *testing.T) {
+	app := setupAuthApp()
+	client, secret := createOAuthClient(t, false, rbac.PermTokensIntrospect)
+	public, _ := createOAuthClient(t, true)
+	relyingParty, relyingPartySecret := createOAuthClient(t, false)
+	revoker, revokerSecret := createOAuthClient(t, false, rbac.PermTokensRevoke)
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	resp := postTokenForm(t, app, "/oauth/introspect", public, "", tokens.Token)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = postTokenForm(t, app, "/oauth/introspect", client, secret+"x", tokens.Token)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = postTokenForm(t, app, "/oauth/introspect", relyingParty, relyingPartySecret, tokens.Token)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = postTokenForm(t, app, "/oauth/introspect", revoker, revokerSecret, tokens.Token)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestRevoke_AccessToken(t *testing.T) {
+	app := setupAuthApp()
+	client, secret := createOAuthClient(t, false, rbac.PermTokensIntrospect, rbac.PermTokensRevoke)
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	resp := postTokenForm(t, app, "/oauth/revoke", client, secret, tokens.Token)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	result := introspect(t, app, client, secret, tokens.Token)
+	assert.Equal(t, false, result["active"])
+	resp = sendJSON(t, app, http.MethodGet, "/auth/sessions", tokens.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Only the access token was revoked, not its session
+	resp = refresh(t, app, tokens.RefreshToken)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRevoke_RefreshTokenEndsSession(t *testing.T) {
+	app := setupAuthApp()
+	client, secret := createOAuthClient(t, false, rbac.PermTokensIntrospect, rbac.PermTokensRevoke)
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	resp := postTokenForm(t, app, "/oauth/revoke", client, secret, tokens.RefreshToken)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	result := introspect(t, app, client, secret, tokens.RefreshToken)
+	assert.Equal(t, false, result["active"])
+	result = introspect(t, app, client, secret, tokens.Token)
+	assert.Equal(t, false, result["active"])
+}
+
+func TestRevoke_OnlyTokensIssuedToTheClient(t *testing.T) {
+	app := setupAuthApp()
+	client, secret := createOAuthClient(t, false, rbac.PermTokensIntrospect)
+	public, _ := createOAuthClient(t, true)
+	relyingParty, relyingPartySecret := createOAuthClient(t, false)
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	// Without tokens:revoke the requests are ignored
+	for _, token := range []string{tokens.Token, tokens.RefreshToken} {
+		resp := postTokenForm(t, app, "/oauth/revoke", public, "", token)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp = postTokenForm(t, app, "/oauth/revoke", relyingParty, relyingPartySecret, token)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp = postTokenForm(t, app, "/oauth/revoke", client, secret, token)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		result := introspect(t, app, client, secret, token)
+		assert.Equal(t, true, result["active"])
+	}
+
+	// A client's own tokens can always be revoked
+	own, _, err := utils.GenerateServiceAccessToken(client.ClientID, client.Scopes)
+	require.NoError(t, err)
+	resp := postTokenForm(t, app, "/oauth/revoke", client, secret, own)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	result := introspect(t, app, client, secret, own)
+	assert.Equal(t, false, result["active"])
+}
+
+func TestRevoke_UnknownToken(t *testing.T) {
+	app := setupAuthApp()
+	client, secret := createOAuthClient(t, false, rbac.PermTokensIntrospect, rbac.PermTokensRevoke)
+
+	resp := postTokenForm(t, app, "/oauth/revoke", client, secret, "garbage")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
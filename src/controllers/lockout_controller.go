@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/lockout"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	loginAttemptCol = configs.GetCollection(configs.DB, "login_attempts")
+	accountLockout  = lockout.Policy{
+		MaxFailures:  config.LoginMaxFailures,
+		LockDuration: config.LoginLockDuration,
+		BaseDelay:    config.LoginBackoffBase,
+		MaxDelay:     config.LoginBackoffMax,
+	}
+	// Many users can share an IP, so it is only locked, and much later
+	ipLockout = lockout.Policy{
+		MaxFailures:  config.LoginIPMaxFailures,
+		LockDuration: config.LoginLockDuration,
+	}
+)
+
+// Failures are counted per email whether or not an account exists, so the
+// throttling doesn't reveal which emails are registered.
+func accountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginRetryAfter reports how long the client has to wait before it may try
+// the password for email again. locked is set when the account is locked
+// rather than just backing off.
+func loginRetryAfter(ctx context.Context, email string, ip string) (wait time.Duration, locked bool, err error) {
+	now := time.Now()
+	cursor, err := loginAttemptCol.Find(ctx, bson.M{
+		"_id":           bson.M{"$in": []string{accountAttemptKey(email), ipAttemptKey(ip)}},
+		"nextAttemptAt": bson.M{"$gt": now},
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	var attempts []models.LoginAttempt
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return 0, false, err
+	}
+
+	for _, attempt := range attempts {
+		if w := attempt.NextAttemptAt.Sub(now); w > wait {
+			wait = w
+		}
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+			locked = true
+		}
+	}
+	return wait, locked, nil
+}
+
+// recordFailure counts a failed attempt for key and applies the policy. It
+// reports whether this failure locked the key.
+func recordFailure(ctx context.Context, key string, policy lockout.Policy) (bool, error) {
+	now := time.Now()
+
+	// $inc keeps concurrent failures from overwriting each other's count
+	var attempt models.LoginAttempt
+	err := loginAttemptCol.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{"$inc": bson.M{"failures": 1}, "$set": bson.M{"lastFailureAt": now}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		return false, err
+	}
+
+	decision := policy.AfterFailure(attempt.Failures, now)
+	expiresAt := now.Add(config.LoginFailureWindow)
+	if decision.NextAttemptAt.After(expiresAt) {
+		expiresAt = decision.NextAttemptAt
+	}
+	set := bson.M{"nextAttemptAt": decision.NextAttemptAt, "expiresAt": expiresAt}
+	if decision.Locked {
+		// The lock itself is the penalty, start counting afresh once it ends
+		set["lockedUntil"] = decision.NextAttemptAt
+		set["failures"] = 0
+	}
+	_, err = loginAttemptCol.UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": set})
+	return decision.Locked, err
+}
+
+// recordLoginFailure counts a wrong password against the account and the
+// client IP, and tells the owner when their account gets locked.
+func recordLoginFailure(ctx context.Context, email string, ip string) {
+	locked, err := recordFailure(ctx, accountAttemptKey(email), accountLockout)
+	if err != nil {
+		log.Printf("❌ Failed to record login failure for %s: %v", email, err)
+	}
+	if _, err := recordFailure(ctx, ipAttemptKey(ip), ipLockout); err != nil {
+		log.Printf("❌ Failed to record login failure for %s: %v", ip, err)
+	}
+
+	if locked {
+		log.Printf("🚨 Account %s locked after %d failed logins", email, accountLockout.MaxFailures)
+		sendAccountLockedEmail(email, ip)
+	}
+}
+
+// clearLoginFailures resets the account's counter after a successful login.
+// The IP counter is left alone so a valid login can't be used to reset it.
+func clearLoginFailures(ctx context.Context, email string) {
+	if _, err := loginAttemptCol.DeleteOne(ctx, bson.M{"_id": accountAttemptKey(email)}); err != nil {
+		log.Printf("⚠️ Failed to clear login failures for %s: %v", email, err)
+	}
+}
+
+// rejectThrottledLogin answers a login attempt made before the backoff or
+// lock has expired.
+func rejectThrottledLogin(c *fiber.Ctx, wait time.Duration, locked bool) error {
+	c.Set(fiber.HeaderRetryAfter, fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+	message := "Too many failed attempts, try again later"
+	if locked {
+		message = "Account temporarily locked due to too many failed attempts"
+	}
+	return responses.SendErrorResponse(c, fiber.StatusTooManyRequests, responses.ErrCodeTooManyRequests, message, nil)
+}
+
+func sendAccountLockedEmail(email string, ip string) {
+	// Only accounts that exist get an email
+	count, err := authCol.CountDocuments(context.Background(), bson.M{"email": email})
+	if err != nil || count == 0 {
+		return
+	}
+	if producer == nil {
+		log.Println("⚠️ RabbitMQ producer not initialized, skipping email notification")
+		return
+	}
+
+	emailData := structure.EmailData{
+		To:       email,
+		Subject:  "Your account has been temporarily locked",
+		Template: "account_locked",
+		Data: map[string]string{
+			"lockedForMinutes": fmt.Sprint(int(config.LoginLockDuration.Minutes())),
+			"ip":               ip,
+		},
+	}
+	if err := producer.Publish(context.Background(), emailData); err != nil {
+		log.Printf("❌ Failed to publish account locked email: %v", err)
+	}
+}
+
+// UnlockAccount lets an admin lift a lock before it expires.
+func UnlockAccount(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := primitive.ObjectIDFromHex(c.Params("authId"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid account ID", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Account not found", nil)
+	}
+
+	if _, err := loginAttemptCol.DeleteOne(ctx, bson.M{"_id": accountAttemptKey(user.Email)}); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to unlock account", map[string]string{"error": err.Error()})
+	}
+
+	log.Printf("✅ Account %s unlocked by %v", user.Email, c.Locals("email"))
+	recordAudit(c, auditAdminUnlock, auditSuccess, user.ID, user.Email, nil)
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Account unlocked", nil)
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/responses"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var loginHistoryCol = configs.GetCollection(configs.DB, "login_history")
+
+// Sign-in methods recorded in the login history
+const (
+	loginMethodPassword  = "password"
+	loginMethodMagicLink = "magic_link"
+	loginMethodOAuth     = "oauth"
+	loginMethodWebAuthn  = "webauthn"
+	loginMethodMFA       = "mfa"
+)
+
+// recordLoginEvent adds a sign-in attempt to the login history. Failing to
+// record it doesn't fail the login.
+func recordLoginEvent(c *fiber.Ctx, authID primitive.ObjectID, email string, method string, success bool, reason string) {
+	now := time.Now()
+	event := models.LoginEvent{
+		AuthID:    authID,
+		Email:     email,
+		Method:    method,
+		Success:   success,
+		Reason:    reason,
+		IP:        c.IP(),
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		CreatedAt: now,
+		ExpiresAt: now.Add(config.LoginHistoryRetention),
+	}
+	if _, err := loginHistoryCol.InsertOne(context.Background(), event); err != nil {
+		log.Printf("⚠️ Failed to record login event for %s: %v", email, err)
+	}
+
+	outcome := auditSuccess
+	if !success {
+		outcome = auditFailure
+	}
+	recordAudit(c, auditLogin, outcome, authID, email, map[string]string{"method": method, "reason": reason})
+}
+
+// GetLoginHistory lists an account's sign-in attempts, newest first.
+func GetLoginHistory(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := primitive.ObjectIDFromHex(c.Params("authId"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid account ID", nil)
+	}
+	page, limit := pageParams(c)
+
+	filter := bson.M{"authId": authID}
+	total, err := loginHistoryCol.CountDocuments(ctx, filter)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to fetch login history", map[string]string{"error": err.Error()})
+	}
+	cursor, err := loginHistoryCol.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+		SetSkip((page-1)*limit).
+		SetLimit(limit))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to fetch login history", map[string]string{"error": err.Error()})
+	}
+	events := []models.LoginEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to fetch login history", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "success", fiber.Map{
+		"data":  events,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
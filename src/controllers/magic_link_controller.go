@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// magicLinkSentMessage is returned whether or not the email belongs to an
+// account, so the endpoint can't be used to probe for registered emails.
+const magicLinkSentMessage = "If an account exists for this email, a sign-in link has been sent"
+
+func RequestMagicLink(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.MagicLinkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	if producer == nil {
+		log.Println("⚠️ RabbitMQ producer not initialized, skipping email notification")
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Email service unavailable", nil)
+	}
+
+	var user models.Auth
+	if err := authCol.FindOne(ctx, bson.M{"email": req.Email}).Decode(&user); err != nil {
+		return responses.SendSuccessResponse(c, fiber.StatusOK, magicLinkSentMessage, nil)
+	}
+
+	// Storing the new link ID invalidates every link sent before
+	linkID := uuid.New().String()
+	_, err := authCol.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"magicLinkId": linkID}})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to create sign-in link", map[string]string{"error": err.Error()})
+	}
+
+	token, err := utils.GenerateMagicLinkToken(user.ID.Hex(), user.Email, linkID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to create sign-in link", map[string]string{"error": err.Error()})
+	}
+	link := config.MagicLinkURL + "?token=" + url.QueryEscape(token)
+
+	emailData := structure.EmailData{
+		To:       user.Email,
+		Subject:  "Your sign-in link",
+		Template: "magic_login",
+		Link:     link,
+		Data: map[string]string{
+			"link":             link,
+			"expiresInMinutes": fmt.Sprint(int(config.MagicLinkTTL.Minutes())),
+		},
+	}
+	if err := producer.Publish(context.Background(), emailData); err != nil {
+		// Don't reveal the account exists by failing only for known emails
+		log.Printf("❌ Failed to publish magic link email: %v", err)
+		authCol.UpdateOne(ctx, bson.M{"_id": user.ID, "magicLinkId": linkID}, bson.M{"$unset": bson.M{"magicLinkId": ""}})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, magicLinkSentMessage, nil)
+}
+
+func ConsumeMagicLink(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.MagicLinkConsumeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	claims, err := utils.ParseTypedJWT(req.Token, utils.TokenUseMagicLink)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid or expired sign-in link", nil)
+	}
+	sub, _ := claims.GetSubject()
+	email, _ := claims["email"].(string)
+	linkID, _ := claims["jti"].(string)
+	authID, err := primitive.ObjectIDFromHex(sub)
+	if err != nil || email == "" || linkID == "" {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid or expired sign-in link", nil)
+	}
+
+	// Consuming the link proves control of the mailbox, which is everything
+	// email verification asks for.
+	var user models.Auth
+	err = authCol.FindOneAndUpdate(ctx,
+		bson.M{"_id": authID, "email": email, "magicLinkId": linkID},
+		bson.M{
+			"$set":   bson.M{"isVerified": true, "otp": "", "otpExpiresAt": time.Time{}},
+			"$unset": bson.M{"magicLinkId": ""},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid or expired sign-in link", nil)
+	}
+
+	return completeLogin(ctx, c, user, loginMethodMagicLink)
+}
@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/totp"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	recoveryCodeCount = 10
+	// maxMFAAttempts wrong codes invalidate the challenge, forcing a new
+	// password login before more codes can be tried.
+	maxMFAAttempts = 5
+	// totpSkew accepts codes from one period before or after the current one
+	totpSkew = 1
+)
+
+// startMFAChallenge stores a new challenge on the account and returns the
+// challenge token the client has to send back with its second factor.
+func startMFAChallenge(ctx context.Context, user models.Auth) (string, error) {
+	challengeID := uuid.New().String()
+	_, err := authCol.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"mfaChallengeId": challengeID, "mfaChallengeAttempts": 0},
+	})
+	if err != nil {
+		return "", err
+	}
+	return utils.GenerateMFAChallengeToken(user.ID.Hex(), challengeID)
+}
+
+// generateRecoveryCodes returns fresh recovery codes for the user to write down
+// and their hashes to store.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, utils.HashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode accepts codes the way users tend to type them back.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func findAuthByID(ctx context.Context, authID primitive.ObjectID) (models.Auth, error) {
+	var user models.Auth
+	err := authCol.FindOne(ctx, bson.M{"_id": authID}).Decode(&user)
+	return user, err
+}
+
+func EnrollMFA(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "User not found", nil)
+	}
+	if user.MFAEnabled {
+		return responses.SendErrorResponse(c, fiber.StatusConflict, responses.ErrCodeDuplicate, "Two-factor authentication is already enabled", nil)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to generate secret", map[string]string{"error": err.Error()})
+	}
+
+	// The secret only becomes active once the user proves their app has it
+	_, err = authCol.UpdateOne(ctx, bson.M{"_id": authID}, bson.M{"$set": bson.M{"totpPendingSecret": secret}})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to start enrollment", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Scan the QR code with your authenticator app and confirm with a code", fiber.Map{
+		"secret":     secret,
+		"otpauthUri": totp.URI(config.MFAIssuer, user.Email, secret),
+	})
+}
+
+func ConfirmMFA(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.MFAConfirmRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "User not found", nil)
+	}
+	if user.MFAEnabled {
+		return responses.SendErrorResponse(c, fiber.StatusConflict, responses.ErrCodeDuplicate, "Two-factor authentication is already enabled", nil)
+	}
+	if user.TOTPPendingSecret == "" {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "No enrollment in progress", nil)
+	}
+
+	step, ok := totp.Validate(user.TOTPPendingSecret, req.Code, time.Now(), totpSkew, 0)
+	if !ok {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid code", nil)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to generate recovery codes", map[string]string{"error": err.Error()})
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"mfaEnabled":    true,
+			"totpSecret":    user.TOTPPendingSecret,
+			"totpLastStep":  step,
+			"recoveryCodes": hashes,
+		},
+		"$unset": bson.M{"totpPendingSecret": ""},
+	}
+	if _, err := authCol.UpdateOne(ctx, bson.M{"_id": authID}, update); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to enable two-factor authentication", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Two-factor authentication enabled. Store your recovery codes somewhere safe, they are shown only once.", fiber.Map{
+		"recoveryCodes": codes,
+	})
+}
+
+func VerifyMFA(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.MFAVerifyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	claims, err := utils.ParseTypedJWT(req.ChallengeToken, utils.TokenUseMFAChallenge)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid or expired challenge", nil)
+	}
+	sub, _ := claims.GetSubject()
+	challengeID, _ := claims["jti"].(string)
+	authID, err := primitive.ObjectIDFromHex(sub)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid or expired challenge", nil)
+	}
+
+	user, err := findAuthByID(ctx, authID)
+	if err != nil || !user.MFAEnabled || challengeID == "" || user.MFAChallengeID != challengeID {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid or expired challenge", nil)
+	}
+
+	// Wrong codes count against the account like wrong passwords
+	wait, locked, err := loginRetryAfter(ctx, user.Email, c.IP())
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to check login attempts", map[string]string{"error": err.Error()})
+	}
+	if wait > 0 {
+		return rejectThrottledLogin(c, wait, locked)
+	}
+
+	// The challenge filter makes every update below fail if the challenge was
+	// consumed concurrently.
+	filter := bson.M{"_id": authID, "mfaChallengeId": challengeID}
+
+	// Count the attempt before checking the code, so parallel guesses can't
+	// get past the limit
+	err = authCol.FindOneAndUpdate(ctx,
+		bson.M{"_id": authID, "mfaChallengeId": challengeID, "mfaChallengeAttempts": bson.M{"$not": bson.M{"$gte": maxMFAAttempts}}},
+		bson.M{"$inc": bson.M{"mfaChallengeAttempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid or expired challenge", nil)
+	}
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to verify code", map[string]string{"error": err.Error()})
+	}
+
+	update := bson.M{"$unset": bson.M{"mfaChallengeId": "", "mfaChallengeAttempts": ""}}
+	verified := false
+	if req.Code != "" {
+		if step, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now(), totpSkew, user.TOTPLastStep); ok {
+			update["$set"] = bson.M{"totpLastStep": step}
+			verified = true
+		}
+	} else {
+		codeHash := utils.HashToken(normalizeRecoveryCode(req.RecoveryCode))
+		for _, stored := range user.RecoveryCodes {
+			if stored == codeHash {
+				filter["recoveryCodes"] = codeHash
+				update["$pull"] = bson.M{"recoveryCodes": codeHash}
+				verified = true
+				break
+			}
+		}
+	}
+
+	if !verified {
+		recordLoginFailure(ctx, user.Email, c.IP())
+		recordLoginEvent(c, user.ID, user.Email, loginMethodMFA, false, "invalid code")
+		if user.MFAChallengeAttempts >= maxMFAAttempts {
+			if _, err := authCol.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"mfaChallengeId": "", "mfaChallengeAttempts": ""}}); err != nil {
+				return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to verify code", map[string]string{"error": err.Error()})
+			}
+			return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Too many invalid codes, please log in again", nil)
+		}
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid code", nil)
+	}
+
+	result, err := authCol.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to verify code", map[string]string{"error": err.Error()})
+	}
+	if result.ModifiedCount == 0 {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid or expired challenge", nil)
+	}
+	clearLoginFailures(ctx, user.Email)
+
+	return finishLogin(ctx, c, user, loginMethodMFA)
+}
+
+func DisableMFA(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.MFADisableRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "User not found", nil)
+	}
+	if !user.MFAEnabled {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Two-factor authentication is not enabled", nil)
+	}
+
+	if !checkPassword(user.Password, req.Password) {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid credentials", nil)
+	}
+	if _, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now(), totpSkew, user.TOTPLastStep); !ok {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid code", nil)
+	}
+
+	update := bson.M{
+		"$set": bson.M{"mfaEnabled": false},
+		"$unset": bson.M{
+			"totpSecret":           "",
+			"totpPendingSecret":    "",
+			"totpLastStep":         "",
+			"recoveryCodes":        "",
+			"mfaChallengeId":       "",
+			"mfaChallengeAttempts": "",
+		},
+	}
+	if _, err := authCol.UpdateOne(ctx, bson.M{"_id": authID}, update); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to disable two-factor authentication", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Two-factor authentication disabled", nil)
+}
@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/oauth"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+var (
+	oauthStateCol  = configs.GetCollection(configs.DB, "oauth_states")
+	oauthProviders = loadOAuthProviders()
+)
+
+// errUnverifiedEmail means the provider didn't vouch for the user's email, so
+// we can neither link it to an existing account nor create a new one.
+var errUnverifiedEmail = errors.New("provider did not return a verified email")
+
+// loadOAuthProviders enables every provider that has a client ID configured.
+func loadOAuthProviders() map[string]oauth.Provider {
+	providers := make(map[string]oauth.Provider)
+	redirectURL := func(name string) string {
+		return strings.TrimRight(config.OAuthRedirectBaseURL, "/") + "/auth/oauth/" + name + "/callback"
+	}
+	providerConfig := func(name string, pc configs.OAuthProviderConfig) oauth.Config {
+		return oauth.Config{
+			ClientID:     pc.ClientID,
+			ClientSecret: pc.ClientSecret,
+			RedirectURL:  redirectURL(name),
+			AuthURL:      pc.AuthURL,
+			TokenURL:     pc.TokenURL,
+			UserInfoURL:  pc.UserInfoURL,
+		}
+	}
+
+	if config.GitHubOAuth.ClientID != "" {
+		providers["github"] = oauth.NewGitHub(providerConfig("github", config.GitHubOAuth))
+	}
+	if config.GoogleOAuth.ClientID != "" {
+		providers["google"] = oauth.NewGoogle(providerConfig("google", config.GoogleOAuth))
+	}
+	if config.OIDCOAuth.ClientID != "" {
+		name := config.OIDCProviderName
+		providers[name] = oauth.NewOIDC(name, providerConfig(name, config.OIDCOAuth))
+	}
+	return providers
+}
+
+// BeginOAuthLogin redirects the user to the provider's consent page.
+func BeginOAuthLogin(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	provider, ok := oauthProviders[c.Params("provider")]
+	if !ok {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Unknown login provider", nil)
+	}
+
+	state, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to start login", map[string]string{"error": err.Error()})
+	}
+	verifier, err := oauth.GenerateVerifier()
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to start login", map[string]string{"error": err.Error()})
+	}
+	nonce, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to start login", map[string]string{"error": err.Error()})
+	}
+
+	_, err = oauthStateCol.InsertOne(ctx, models.OAuthState{
+		ID:           state,
+		Provider:     provider.Name(),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to start login", map[string]string{"error": err.Error()})
+	}
+
+	return c.Redirect(provider.AuthCodeURL(state, oauth.ChallengeS256(verifier), nonce), fiber.StatusFound)
+}
+
+// OAuthCallback finishes a social login once the provider redirects back.
+func OAuthCallback(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	provider, ok := oauthProviders[c.Params("provider")]
+	if !ok {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Unknown login provider", nil)
+	}
+	if providerErr := c.Query("error"); providerErr != "" {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Login was cancelled or denied", map[string]string{"error": providerErr})
+	}
+	code, stateID := c.Query("code"), c.Query("state")
+	if code == "" || stateID == "" {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Missing code or state", nil)
+	}
+
+	// Deleting the state makes every login attempt single use
+	var state models.OAuthState
+	err := oauthStateCol.FindOneAndDelete(ctx, bson.M{
+		"_id":       stateID,
+		"provider":  provider.Name(),
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&state)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid or expired login state", nil)
+	}
+
+	identity, err := provider.Exchange(ctx, code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("❌ %s login failed: %v", provider.Name(), err)
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Login with provider failed", nil)
+	}
+
+	user, err := linkExternalIdentity(ctx, identity)
+	if errors.Is(err, errUnverifiedEmail) {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Your email is not verified with this provider", nil)
+	}
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to sign in", map[string]string{"error": err.Error()})
+	}
+
+	return completeLogin(ctx, c, user, loginMethodOAuth)
+}
+
+// linkExternalIdentity finds the account an external identity belongs to. An
+// identity we've seen before maps to its account; otherwise it is linked by
+// verified email, or a new pre-verified account without a password is created.
+func linkExternalIdentity(ctx context.Context, identity *oauth.Identity) (models.Auth, error) {
+	var user models.Auth
+	err := authCol.FindOne(ctx, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": identity.Provider, "subject": identity.Subject}},
+	}).Decode(&user)
+	if err == nil {
+		return user, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return user, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return user, errUnverifiedEmail
+	}
+	email := identity.Email
+	link := models.ExternalIdentity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    email,
+		LinkedAt: time.Now(),
+	}
+
+	err = authCol.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		user = models.Auth{
+			ID:         primitive.NewObjectID(),
+			Email:      email,
+			IsVerified: true,
+			Identities: []models.ExternalIdentity{link},
+		}
+		if _, err := authCol.InsertOne(ctx, user); err != nil {
+			return user, err
+		}
+		log.Printf("✅ Created account for %s via %s", email, identity.Provider)
+		return user, nil
+	}
+	if err != nil {
+		return user, err
+	}
+
+	update := bson.M{"$push": bson.M{"identities": link}}
+	if !user.IsVerified {
+		// Someone registered this email without ever proving they own it. The
+		// provider has now proven the real owner is signing in, so drop the
+		// unverified password instead of handing its creator a verified account.
+		update["$set"] = bson.M{"isVerified": true, "password": ""}
+		update["$unset"] = bson.M{"otp": ""}
+	}
+	err = authCol.FindOneAndUpdate(ctx, bson.M{"_id": user.ID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		return user, err
+	}
+	log.Printf("✅ Linked %s account to %s", identity.Provider, email)
+	return user, nil
+}
@@ -0,0 +1,494 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/oauth"
+	"user-auth-profile-service/src/rbac"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const authorizationCodeTTL = 2 * time.Minute
+
+var (
+	oauthClientCol = configs.GetCollection(configs.DB, "oauth_clients")
+	authCodeCol    = configs.GetCollection(configs.DB, "oauth_codes")
+)
+
+// supportedScopes are the OpenID Connect scopes we can issue.
+var supportedScopes = []string{"openid", "profile", "email"}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// oauthError writes an RFC 6749 error response. OAuth clients expect this
+// format rather than our usual response envelope.
+func oauthError(c *fiber.Ctx, status int, code string, description string) error {
+	return c.Status(status).JSON(fiber.Map{"error": code, "error_description": description})
+}
+
+// authorizeError is an invalid authorization request. Redirectable errors are
+// reported to the client's redirect URI; the others can't be, because the
+// client or the redirect URI itself is the problem.
+type authorizeError struct {
+	Code         string
+	Description  string
+	Redirectable bool
+}
+
+// validateAuthorizeRequest checks an authorization request against the
+// registered client. PKCE is required for every client.
+func validateAuthorizeRequest(ctx context.Context, req structure.AuthorizeRequest) (models.OAuthClient, *authorizeError) {
+	var client models.OAuthClient
+	if err := oauthClientCol.FindOne(ctx, bson.M{"clientId": req.ClientID}).Decode(&client); err != nil {
+		return client, &authorizeError{Code: "invalid_request", Description: "unknown client_id"}
+	}
+	if !containsString(client.RedirectURIs, req.RedirectURI) {
+		return client, &authorizeError{Code: "invalid_request", Description: "redirect_uri is not registered for this client"}
+	}
+
+	if req.ResponseType != "code" {
+		return client, &authorizeError{Code: "unsupported_response_type", Description: "only the code response type is supported", Redirectable: true}
+	}
+	scopes := strings.Fields(req.Scope)
+	if !containsString(scopes, "openid") {
+		return client, &authorizeError{Code: "invalid_scope", Description: "the openid scope is required", Redirectable: true}
+	}
+	for _, scope := range scopes {
+		if !containsString(client.Scopes, scope) {
+			return client, &authorizeError{Code: "invalid_scope", Description: fmt.Sprintf("scope %q is not allowed for this client", scope), Redirectable: true}
+		}
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != "S256" {
+		return client, &authorizeError{Code: "invalid_request", Description: "PKCE with the S256 method is required", Redirectable: true}
+	}
+	return client, nil
+}
+
+// withQuery adds params to the query string of a redirect URI.
+func withQuery(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	query := u.Query()
+	for k, v := range params {
+		query[k] = v
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Authorize validates an authorization request and sends the browser to the
+// login page, which signs the user in and then approves it with
+// ApproveAuthorization. The original parameters are passed along unchanged.
+func Authorize(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.AuthorizeRequest
+	if err := c.QueryParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid authorization request", map[string]string{"error": err.Error()})
+	}
+
+	if _, authErr := validateAuthorizeRequest(ctx, req); authErr != nil {
+		if authErr.Redirectable {
+			params := url.Values{"error": {authErr.Code}, "error_description": {authErr.Description}}
+			if req.State != "" {
+				params.Set("state", req.State)
+			}
+			return c.Redirect(withQuery(req.RedirectURI, params), fiber.StatusFound)
+		}
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid authorization request", map[string]string{"error": authErr.Code, "description": authErr.Description})
+	}
+
+	return c.Redirect(config.OIDCLoginURL+"?"+string(c.Request().URI().QueryString()), fiber.StatusFound)
+}
+
+// ApproveAuthorization is called by the login page for a signed in user. It
+// issues an authorization code and returns the URI to send the browser back to.
+func ApproveAuthorization(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+
+	var req structure.AuthorizeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+
+	client, authErr := validateAuthorizeRequest(ctx, req)
+	if authErr != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid authorization request", map[string]string{"error": authErr.Code, "description": authErr.Description})
+	}
+
+	// The user signed in when their session started. Tokens without a
+	// session are never refreshed, so they were issued at sign in.
+	authTime := time.Now()
+	claims := currentClaims(c)
+	if sid, ok := claims["sid"].(string); ok && sid != "" {
+		authTime, err = sessionAuthTime(ctx, authID, sid)
+		if err != nil {
+			return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Session has ended", nil)
+		}
+	} else if iat, ok := claims["iat"].(float64); ok {
+		authTime = time.Unix(int64(iat), 0)
+	}
+
+	code, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to issue authorization code", map[string]string{"error": err.Error()})
+	}
+	_, err = authCodeCol.InsertOne(ctx, models.AuthorizationCode{
+		ID:            utils.HashToken(code),
+		ClientID:      client.ClientID,
+		AuthID:        authID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(strings.Fields(req.Scope), " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      authTime,
+		ExpiresAt:     time.Now().Add(authorizationCodeTTL),
+	})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to issue authorization code", map[string]string{"error": err.Error()})
+	}
+
+	params := url.Values{"code": {code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Authorization granted", fiber.Map{
+		"redirectUri": withQuery(req.RedirectURI, params),
+	})
+}
+
+// clientCredentials reads the client ID and secret from HTTP Basic auth, or
+// from the form fields when the client uses client_secret_post.
+func clientCredentials(c *fiber.Ctx, formID string, formSecret string) (string, string) {
+	header := c.Get(fiber.HeaderAuthorization)
+	if !strings.HasPrefix(header, "Basic ") {
+		return formID, formSecret
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+	if err != nil {
+		return "", ""
+	}
+	id, secret, _ := strings.Cut(string(decoded), ":")
+	// RFC 6749 form encodes both values before building the header
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	return id, secret
+}
+
+// authenticateClient looks up the calling client and checks its secret.
+// Public clients have no secret and are only identified.
+func authenticateClient(ctx context.Context, clientID string, secret string) (models.OAuthClient, error) {
+	var client models.OAuthClient
+	if clientID == "" {
+		return client, fmt.Errorf("missing client_id")
+	}
+	if err := oauthClientCol.FindOne(ctx, bson.M{"clientId": clientID}).Decode(&client); err != nil {
+		return client, fmt.Errorf("unknown client")
+	}
+	if client.Public {
+		return client, nil
+	}
+	if secret == "" || subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return client, fmt.Errorf("invalid client secret")
+	}
+	return client, nil
+}
+
+// Token is the OAuth token endpoint.
+func Token(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+
+	var req structure.TokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_request", "malformed request body")
+	}
+
+	clientID, secret := clientCredentials(c, req.ClientID, req.ClientSecret)
+	client, err := authenticateClient(ctx, clientID, secret)
+	if err != nil {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="token"`)
+		return oauthError(c, fiber.StatusUnauthorized, "invalid_client", err.Error())
+	}
+
+	switch req.GrantType {
+	case "authorization_code":
+		return exchangeAuthorizationCode(ctx, c, client, req)
+	case "client_credentials":
+		return grantClientCredentials(c, client, req)
+	default:
+		return oauthError(c, fiber.StatusBadRequest, "unsupported_grant_type", "unsupported grant_type")
+	}
+}
+
+func exchangeAuthorizationCode(ctx context.Context, c *fiber.Ctx, client models.OAuthClient, req structure.TokenRequest) error {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_request", "code and code_verifier are required")
+	}
+
+	// Codes are single use, deleting it on redemption stops replays
+	var code models.AuthorizationCode
+	err := authCodeCol.FindOneAndDelete(ctx, bson.M{
+		"_id":       utils.HashToken(req.Code),
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&code)
+	if err != nil {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_grant", "invalid or expired code")
+	}
+	if code.ClientID != client.ClientID || code.RedirectURI != req.RedirectURI {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_grant", "code was issued to another client or redirect_uri")
+	}
+	if subtle.ConstantTimeCompare([]byte(oauth.ChallengeS256(req.CodeVerifier)), []byte(code.CodeChallenge)) != 1 {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_grant", "code_verifier does not match the code challenge")
+	}
+
+	user, err := findAuthByID(ctx, code.AuthID)
+	if err != nil {
+		return oauthError(c, fiber.StatusBadRequest, "invalid_grant", "account no longer exists")
+	}
+
+	accessToken, expiresAt, err := utils.GenerateClientAccessToken(user.ID.Hex(), user.Email, client.ClientID, code.Scope)
+	if err != nil {
+		return oauthError(c, fiber.StatusInternalServerError, "server_error", "failed to issue tokens")
+	}
+	idToken, err := utils.GenerateIDToken(user.ID.Hex(), client.ClientID, code.Nonce, code.AuthTime, userInfoClaims(ctx, user, strings.Fields(code.Scope)))
+	if err != nil {
+		return oauthError(c, fiber.StatusInternalServerError, "server_error", "failed to issue tokens")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(time.Until(expiresAt).Seconds()),
+		"id_token":     idToken,
+		"scope":        code.Scope,
+	})
+}
+
+// grantClientCredentials issues a machine client a token for itself. Without
+// a scope parameter the client gets every scope it is allowed.
+func grantClientCredentials(c *fiber.Ctx, client models.OAuthClient, req structure.TokenRequest) error {
+	if !client.Machine {
+		return oauthError(c, fiber.StatusBadRequest, "unauthorized_client", "client is not allowed to use the client_credentials grant")
+	}
+
+	scopes := client.Scopes
+	if req.Scope != "" {
+		scopes = strings.Fields(req.Scope)
+		for _, scope := range scopes {
+			if !containsString(client.Scopes, scope) {
+				return oauthError(c, fiber.StatusBadRequest, "invalid_scope", fmt.Sprintf("scope %q is not allowed for this client", scope))
+			}
+		}
+	}
+
+	accessToken, expiresAt, err := utils.GenerateServiceAccessToken(client.ClientID, scopes)
+	if err != nil {
+		return oauthError(c, fiber.StatusInternalServerError, "server_error", "failed to issue tokens")
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(time.Until(expiresAt).Seconds()),
+		"scope":        strings.Join(scopes, " "),
+	})
+}
+
+// userInfoClaims returns the OpenID Connect claims about user that the given
+// scopes allow, with profile data taken from the user's profile if they have one.
+func userInfoClaims(ctx context.Context, user models.Auth, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{}
+	if containsString(scopes, "email") {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.IsVerified
+	}
+	if containsString(scopes, "profile") {
+		var profile models.User
+		if err := userCollection.FindOne(ctx, bson.M{"email": user.Email}).Decode(&profile); err == nil {
+			claims["name"] = profile.Name
+			claims["preferred_username"] = profile.Username
+		}
+	}
+	return claims
+}
+
+// UserInfo is the OpenID Connect userinfo endpoint.
+func UserInfo(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid_token"})
+	}
+
+	// Tokens issued to OAuth clients are limited to their scopes, our own
+	// access tokens can read everything.
+	scopes := supportedScopes
+	if scope, ok := currentClaims(c)["scope"].(string); ok {
+		scopes = strings.Fields(scope)
+		if !containsString(scopes, "openid") {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "insufficient_scope"})
+		}
+	}
+
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid_token"})
+	}
+
+	claims := userInfoClaims(ctx, user, scopes)
+	claims["sub"] = user.ID.Hex()
+	return c.Status(fiber.StatusOK).JSON(claims)
+}
+
+// CreateOAuthClient registers a client application. The secret of a
+// confidential client is only returned here.
+func CreateOAuthClient(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ownerID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+
+	var req structure.OAuthClientRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	scopes := req.Scopes
+	if req.Machine {
+		// Machine clients sign nobody in, their scopes are permissions that
+		// the admin registering them must hold
+		if req.Public {
+			return responses.SendValidationError(c, map[string]string{"Public": "machine clients can't be public"})
+		}
+		if len(scopes) == 0 {
+			return responses.SendValidationError(c, map[string]string{"Scopes": "machine clients need at least one scope"})
+		}
+		for _, scope := range scopes {
+			if !rbac.IsPermission(scope) || !hasPermission(c, scope) {
+				return responses.SendValidationError(c, map[string]string{"Scopes": fmt.Sprintf("unsupported scope '%s'", scope)})
+			}
+		}
+		req.RedirectURIs = nil
+	} else {
+		if len(scopes) == 0 {
+			scopes = supportedScopes
+		}
+		for _, scope := range scopes {
+			if !containsString(supportedScopes, scope) {
+				return responses.SendValidationError(c, map[string]string{"Scopes": fmt.Sprintf("unsupported scope '%s'", scope)})
+			}
+		}
+	}
+
+	client := models.OAuthClient{
+		ClientID:     uuid.New().String(),
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		Scopes:       scopes,
+		Public:       req.Public,
+		Machine:      req.Machine,
+		OwnerID:      ownerID,
+		CreatedAt:    time.Now(),
+	}
+	var secret string
+	if !client.Public {
+		secret, err = utils.GenerateOpaqueToken(32)
+		if err != nil {
+			return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to create client", map[string]string{"error": err.Error()})
+		}
+		client.SecretHash = utils.HashToken(secret)
+	}
+
+	if _, err := oauthClientCol.InsertOne(ctx, client); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to create client", map[string]string{"error": err.Error()})
+	}
+
+	data := fiber.Map{"client": client}
+	if secret != "" {
+		data["clientSecret"] = secret
+	}
+	return responses.SendSuccessResponse(c, fiber.StatusCreated, "Client created", data)
+}
+
+func ListOAuthClients(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := oauthClientCol.Find(ctx, bson.M{})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to fetch clients", map[string]string{"error": err.Error()})
+	}
+	clients := []models.OAuthClient{}
+	if err := cursor.All(ctx, &clients); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to fetch clients", map[string]string{"error": err.Error()})
+	}
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Clients fetched", clients)
+}
+
+func DeleteOAuthClient(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := oauthClientCol.DeleteOne(ctx, bson.M{"clientId": c.Params("clientId")})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to delete client", map[string]string{"error": err.Error()})
+	}
+	if result.DeletedCount == 0 {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Client not found", nil)
+	}
+
+	// Outstanding codes are useless without the client, drop them right away
+	if _, err := authCodeCol.DeleteMany(ctx, bson.M{"clientId": c.Params("clientId")}); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to delete client", map[string]string{"error": err.Error()})
+	}
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Client deleted", nil)
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/oauth"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestApproveAuthorization_AuthTimeIsTheLogin(t *testing.T) {
+	app := setupAuthApp()
+	client := models.OAuthClient{
+		ID:           primitive.NewObjectID(),
+		ClientID:     "client-" + uuid.New().String(),
+		Name:         "Test relying party",
+		RedirectURIs: []string{"https://rp.forgeit.test/callback"},
+		Scopes:       supportedScopes,
+		Public:       true,
+		CreatedAt:    time.Now(),
+	}
+	_, err := oauthClientCol.InsertOne(context.TODO(), client)
+	require.NoError(t, err)
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	claims, err := utils.ParseJWT(tokens.Token)
+	require.NoError(t, err)
+	var session models.Session
+	require.NoError(t, sessionCol.FindOne(context.TODO(), bson.M{"_id": claims["sid"]}).Decode(&session))
+
+	// Refreshing later must not move auth_time
+	time.Sleep(1100 * time.Millisecond)
+	resp := refresh(t, app, tokens.RefreshToken)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var rotated tokenPair
+	decodeData(t, resp, &rotated)
+
+	verifier, err := oauth.GenerateVerifier()
+	require.NoError(t, err)
+	resp = sendJSON(t, app, http.MethodPost, "/authorize", rotated.Token, structure.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            client.ClientID,
+		RedirectURI:         client.RedirectURIs[0],
+		Scope:               "openid",
+		CodeChallenge:       oauth.ChallengeS256(verifier),
+		CodeChallengeMethod: "S256",
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var data struct {
+		RedirectURI string `json:"redirectUri"`
+	}
+	decodeData(t, resp, &data)
+	redirect, err := url.Parse(data.RedirectURI)
+	require.NoError(t, err)
+
+	var code models.AuthorizationCode
+	require.NoError(t, authCodeCol.FindOne(context.TODO(), bson.M{"_id": utils.HashToken(redirect.Query().Get("code"))}).Decode(&code))
+	assert.WithinDuration(t, session.CreatedAt, code.AuthTime, time.Millisecond)
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/password"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	passwordPolicy = loadPasswordPolicy()
+	passwordHasher = password.Hasher{
+		Algorithm: config.PasswordHashAlgorithm,
+		Argon2: password.Argon2Params{
+			Memory:      uint32(config.Argon2MemoryKiB),
+			Iterations:  uint32(config.Argon2Iterations),
+			Parallelism: uint8(config.Argon2Parallelism),
+			SaltLength:  password.DefaultArgon2Params.SaltLength,
+			KeyLength:   password.DefaultArgon2Params.KeyLength,
+		},
+		BcryptCost: config.BcryptCost,
+	}
+)
+
+func loadPasswordPolicy() password.Policy {
+	policy := password.Policy{
+		MinLength:      config.PasswordMinLength,
+		MaxLength:      config.PasswordMaxLength,
+		MinCharClasses: config.PasswordMinClasses,
+		MinScore:       config.PasswordMinScore,
+	}
+	if config.BreachedPasswordsDir != "" {
+		policy.Breached = &password.BreachedList{Dir: config.BreachedPasswordsDir}
+	}
+	return policy
+}
+
+// passwordPolicyErrors checks a new password for the account with email. The
+// violations come back as validation errors on field, ready for
+// responses.SendValidationError, or nil when the password is acceptable.
+func passwordPolicyErrors(field string, newPassword string, email string) map[string]string {
+	violations, err := passwordPolicy.Check(newPassword, email)
+	if err != nil {
+		// Don't lock users out because the breach list can't be read
+		log.Printf("⚠️ Breached password check failed: %v", err)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(violations))
+	for i, v := range violations {
+		messages[i] = v.Message
+	}
+	return map[string]string{field: "Password " + strings.Join(messages, "; ")}
+}
+
+// reusesPassword reports whether newPassword is one of the account's last
+// PasswordHistorySize passwords, counting the current one.
+func reusesPassword(user models.Auth, newPassword string) bool {
+	if config.PasswordHistorySize <= 0 {
+		return false
+	}
+	hashes := append([]string{user.Password}, user.PasswordHistory...)
+	if len(hashes) > config.PasswordHistorySize {
+		hashes = hashes[:config.PasswordHistorySize]
+	}
+	for _, hash := range hashes {
+		if checkPassword(hash, newPassword) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextPasswordHistory is the history to store once the current password is
+// replaced: the current hash goes first and the oldest ones drop off. Together
+// with the new password it covers the last PasswordHistorySize passwords.
+func nextPasswordHistory(user models.Auth) []string {
+	history := user.PasswordHistory
+	if user.Password != "" {
+		history = append([]string{user.Password}, history...)
+	}
+	keep := config.PasswordHistorySize - 1
+	if keep < 0 {
+		keep = 0
+	}
+	if len(history) > keep {
+		history = history[:keep]
+	}
+	return history
+}
+
+// checkPassword reports whether secret matches hash, whichever algorithm made
+// it. Accounts without a password (social login only) never match.
+func checkPassword(hash string, secret string) bool {
+	if hash == "" {
+		return false
+	}
+	ok, err := passwordHasher.Verify(hash, secret)
+	if err != nil {
+		log.Printf("⚠️ Failed to verify password hash: %v", err)
+	}
+	return ok
+}
+
+// upgradePasswordHash rehashes the password of an account that just proved it
+// knows it, when the stored hash uses an outdated algorithm or cost. The
+// update only applies if the hash hasn't changed in the meantime.
+func upgradePasswordHash(ctx context.Context, user models.Auth, plain string) {
+	if !passwordHasher.NeedsRehash(user.Password) {
+		return
+	}
+	hash, err := passwordHasher.Hash(plain)
+	if err != nil {
+		log.Printf("⚠️ Failed to rehash password for %s: %v", user.Email, err)
+		return
+	}
+	_, err = authCol.UpdateOne(ctx,
+		bson.M{"_id": user.ID, "password": user.Password},
+		bson.M{"$set": bson.M{"password": hash}},
+	)
+	if err != nil {
+		log.Printf("⚠️ Failed to store upgraded password hash for %s: %v", user.Email, err)
+	}
+}
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var passwordResetCol = configs.GetCollection(configs.DB, "password_resets")
+
+// resetSentMessage is returned whether or not the email belongs to an
+// account, so the endpoint can't be used to probe for registered emails.
+const resetSentMessage = "If an account exists for this email, a password reset link has been sent"
+
+// Used tokens are kept this long so we can tell who reset a password
+const passwordResetRetention = 30 * 24 * time.Hour
+
+// newPasswordReset creates the reset token for an account. Any token sent
+// before is deactivated first, only the newest link works.
+func newPasswordReset(ctx context.Context, user models.Auth) (string, error) {
+	selector, err := utils.GenerateOpaqueToken(16)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = passwordResetCol.UpdateMany(ctx,
+		bson.M{"authId": user.ID, "active": true},
+		bson.M{"$unset": bson.M{"active": ""}},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = passwordResetCol.InsertOne(ctx, models.PasswordReset{
+		ID:           selector,
+		AuthID:       user.ID,
+		VerifierHash: utils.HashToken(verifier),
+		Active:       true,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(config.PasswordResetTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return selector + ":" + verifier, nil
+}
+
+// findPasswordReset returns the active reset a token belongs to.
+func findPasswordReset(ctx context.Context, token string) (*models.PasswordReset, error) {
+	selector, verifier, ok := strings.Cut(token, ":")
+	if !ok || selector == "" || verifier == "" {
+		return nil, fmt.Errorf("malformed reset token")
+	}
+
+	var reset models.PasswordReset
+	err := passwordResetCol.FindOne(ctx, bson.M{
+		"_id":       selector,
+		"active":    true,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&reset)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(reset.VerifierHash), []byte(utils.HashToken(verifier))) != 1 {
+		return nil, fmt.Errorf("reset token verifier mismatch")
+	}
+	return &reset, nil
+}
+
+func ForgotPassword(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	if producer == nil {
+		log.Println("⚠️ RabbitMQ producer not initialized, skipping email notification")
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Email service unavailable", nil)
+	}
+
+	var user models.Auth
+	if err := authCol.FindOne(ctx, bson.M{"email": req.Email}).Decode(&user); err != nil {
+		return responses.SendSuccessResponse(c, fiber.StatusOK, resetSentMessage, nil)
+	}
+
+	token, err := newPasswordReset(ctx, user)
+	if err != nil {
+		// Failing only for known emails would give the account away
+		log.Printf("❌ Failed to create password reset for %s: %v", user.Email, err)
+		return responses.SendSuccessResponse(c, fiber.StatusOK, resetSentMessage, nil)
+	}
+	if err := sendPasswordResetEmail(ctx, user, token); err != nil {
+		log.Printf("❌ Failed to publish password reset email: %v", err)
+	}
+	recordAudit(c, auditPasswordResetRequest, auditSuccess, user.ID, user.Email, nil)
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, resetSentMessage, nil)
+}
+
+// sendPasswordResetEmail emails the reset link for token. If the email can't
+// be sent the token is dropped, as nobody could use it.
+func sendPasswordResetEmail(ctx context.Context, user models.Auth, token string) error {
+	link := config.PasswordResetURL + "?token=" + url.QueryEscape(token)
+
+	emailData := structure.EmailData{
+		To:       user.Email,
+		Subject:  "Reset your password",
+		Template: "reset_password",
+		Link:     link,
+		Data: map[string]string{
+			"token":            token,
+			"link":             link,
+			"expiresInMinutes": fmt.Sprint(int(config.PasswordResetTTL.Minutes())),
+		},
+	}
+	if err := producer.Publish(context.Background(), emailData); err != nil {
+		selector, _, _ := strings.Cut(token, ":")
+		passwordResetCol.DeleteOne(ctx, bson.M{"_id": selector})
+		return err
+	}
+	return nil
+}
+
+func ResetPassword(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.ResetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	if req.Password != req.ConfirmPassword {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Passwords do not match", nil)
+	}
+
+	reset, err := findPasswordReset(ctx, req.Token)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Reset token is invalid or expired", nil)
+	}
+	user, err := findAuthByID(ctx, reset.AuthID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Reset token is invalid or expired", nil)
+	}
+
+	if policyErrors := passwordPolicyErrors("Password", req.Password, user.Email); policyErrors != nil {
+		return responses.SendValidationError(c, policyErrors)
+	}
+	if reusesPassword(user, req.Password) {
+		return responses.SendValidationError(c, map[string]string{"Password": "Password was used recently, choose a different one"})
+	}
+
+	hashedPassword, err := passwordHasher.Hash(req.Password)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to hash password", map[string]string{"error": err.Error()})
+	}
+
+	// Consuming the token first means two requests racing with the same link
+	// can't both reset the password
+	now := time.Now()
+	result, err := passwordResetCol.UpdateOne(ctx,
+		bson.M{"_id": reset.ID, "active": true},
+		bson.M{
+			"$set": bson.M{
+				"usedAt":          now,
+				"usedByIp":        c.IP(),
+				"usedByUserAgent": c.Get(fiber.HeaderUserAgent),
+				"expiresAt":       now.Add(passwordResetRetention),
+			},
+			"$unset": bson.M{"active": ""},
+		},
+	)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to reset password", map[string]string{"error": err.Error()})
+	}
+	if result.ModifiedCount == 0 {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Reset token is invalid or expired", nil)
+	}
+
+	update := bson.M{"$set": bson.M{"password": hashedPassword, "passwordHistory": nextPasswordHistory(user)}}
+	if _, err := authCol.UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to update password", map[string]string{"error": err.Error()})
+	}
+
+	// Whoever knew the old password shouldn't stay signed in
+	if err := revokeAllTokens(ctx, user.ID); err != nil {
+		log.Printf("⚠️ Failed to revoke sessions after password reset for %s: %v", user.Email, err)
+	}
+	if err := revokeAllAPIKeys(ctx, user.ID); err != nil {
+		log.Printf("⚠️ Failed to revoke API keys after password reset for %s: %v", user.Email, err)
+	}
+	clearLoginFailures(ctx, user.Email)
+
+	recordAudit(c, auditPasswordReset, auditSuccess, user.ID, user.Email, nil)
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Password has been reset successfully", nil)
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/structure"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetPassword(t *testing.T, app *fiber.App, token string, password string) *http.Response {
+	return sendJSON(t, app, http.MethodPost, "/auth/reset-password", "", structure.ResetRequest{
+		Token:           token,
+		Password:        password,
+		ConfirmPassword: password,
+	})
+}
+
+func TestResetPassword_TokenWorksOnce(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	token, err := newPasswordReset(context.TODO(), user)
+	require.NoError(t, err)
+
+	newPassword := randomPassword()
+	resp := resetPassword(t, app, token, newPassword)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = resetPassword(t, app, token, randomPassword())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Whoever knew the old password is signed out
+	resp = refresh(t, app, tokens.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = sendJSON(t, app, http.MethodPost, "/auth/login", "", models.Auth{Email: user.Email, Password: password})
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	login(t, app, user.Email, newPassword)
+}
+
+func TestResetPassword_OnlyNewestTokenWorks(t *testing.T) {
+	app := setupAuthApp()
+	user, _ := createVerifiedUser(t)
+
+	first, err := newPasswordReset(context.TODO(), user)
+	require.NoError(t, err)
+	second, err := newPasswordReset(context.TODO(), user)
+	require.NoError(t, err)
+
+	resp := resetPassword(t, app, first, randomPassword())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = resetPassword(t, app, second, randomPassword())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestResetPassword_WrongVerifier(t *testing.T) {
+	app := setupAuthApp()
+	user, _ := createVerifiedUser(t)
+
+	token, err := newPasswordReset(context.TODO(), user)
+	require.NoError(t, err)
+
+	resp := resetPassword(t, app, token+"x", randomPassword())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp = resetPassword(t, app, "malformed", randomPassword())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// A failed attempt doesn't use the token up
+	resp = resetPassword(t, app, token, randomPassword())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
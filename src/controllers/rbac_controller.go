@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"user-auth-profile-service/src/rbac"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AssignRoles replaces the roles and extra permissions of an account.
+func AssignRoles(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := primitive.ObjectIDFromHex(c.Params("authId"))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid account ID", nil)
+	}
+
+	var req structure.AssignRolesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+	for _, role := range req.Roles {
+		if !rbac.IsRole(role) {
+			return responses.SendValidationError(c, map[string]string{"Roles": fmt.Sprintf("unknown role '%s'", role)})
+		}
+	}
+	for _, perm := range req.Permissions {
+		if !rbac.IsPermission(perm) {
+			return responses.SendValidationError(c, map[string]string{"Permissions": fmt.Sprintf("unknown permission '%s'", perm)})
+		}
+	}
+
+	result, err := authCol.UpdateOne(ctx, bson.M{"_id": authID}, bson.M{"$set": bson.M{
+		"roles":       req.Roles,
+		"permissions": req.Permissions,
+	}})
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to assign roles", map[string]string{"error": err.Error()})
+	}
+	if result.MatchedCount == 0 {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Account not found", nil)
+	}
+
+	// Access tokens carry the old permissions. Refreshing picks up the new
+	// ones, so only the access tokens need to go.
+	if err := utils.RevokeAllAccessTokens(ctx, authID); err != nil {
+		log.Printf("⚠️ Failed to revoke access tokens of %s after role change: %v", authID.Hex(), err)
+	}
+
+	log.Printf("✅ Roles of %s set to %v by %v", authID.Hex(), req.Roles, c.Locals("email"))
+	recordAudit(c, auditAdminAssignRoles, auditSuccess, authID, "", map[string]string{
+		"roles":       strings.Join(req.Roles, ","),
+		"permissions": strings.Join(req.Permissions, ","),
+	})
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Roles updated", fiber.Map{
+		"roles":       req.Roles,
+		"permissions": rbac.Permissions(req.Roles, req.Permissions),
+	})
+}
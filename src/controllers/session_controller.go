@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var sessionCol = configs.GetCollection(configs.DB, "sessions")
+
+// startSession records the device behind a new login and returns the session
+// ID. The owner is emailed when the account has signed in before, but never
+// from this device.
+func startSession(ctx context.Context, c *fiber.Ctx, user models.Auth) (string, error) {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	newDevice, err := isNewDevice(ctx, user.ID, userAgent)
+	if err != nil {
+		log.Printf("⚠️ Failed to check known devices of %s: %v", user.Email, err)
+	}
+
+	now := time.Now()
+	session := models.Session{
+		ID:         uuid.New().String(),
+		AuthID:     user.ID,
+		UserAgent:  userAgent,
+		IP:         c.IP(),
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(config.RefreshTokenTTL),
+	}
+	if _, err := sessionCol.InsertOne(ctx, session); err != nil {
+		return "", err
+	}
+
+	if newDevice {
+		sendNewDeviceEmail(user.Email, session)
+	}
+	return session.ID, nil
+}
+
+// sessionAuthTime returns when the user signed in to the session sid. Unlike
+// the iat of its access tokens it doesn't change when they are refreshed.
+func sessionAuthTime(ctx context.Context, authID primitive.ObjectID, sid string) (time.Time, error) {
+	var session models.Session
+	err := sessionCol.FindOne(ctx, bson.M{"_id": sid, "authId": authID, "revokedAt": bson.M{"$exists": false}}).Decode(&session)
+	return session.CreatedAt, err
+}
+
+// isNewDevice reports whether the account has signed in successfully before,
+// but never with userAgent.
+func isNewDevice(ctx context.Context, authID primitive.ObjectID, userAgent string) (bool, error) {
+	known, err := loginHistoryCol.Distinct(ctx, "userAgent", bson.M{"authId": authID, "success": true})
+	if err != nil {
+		return false, err
+	}
+	if len(known) == 0 {
+		return false, nil
+	}
+	for _, ua := range known {
+		if ua == userAgent {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func sendNewDeviceEmail(email string, session models.Session) {
+	if producer == nil {
+		log.Println("⚠️ RabbitMQ producer not initialized, skipping email notification")
+		return
+	}
+
+	emailData := structure.EmailData{
+		To:       email,
+		Subject:  "New sign-in to your account",
+		Template: "new_device_login",
+		Data: map[string]string{
+			"userAgent": session.UserAgent,
+			"ip":        session.IP,
+			"time":      session.CreatedAt.Format(time.RFC1123),
+		},
+	}
+	if err := producer.Publish(context.Background(), emailData); err != nil {
+		log.Printf("❌ Failed to publish new device email: %v", err)
+	}
+}
+
+// touchSession marks the session as seen on a token refresh and keeps it
+// alive as long as its newest refresh token. Sessions of logins from before
+// sessions were recorded are created here.
+func touchSession(ctx context.Context, c *fiber.Ctx, authID primitive.ObjectID, sid string) error {
+	now := time.Now()
+	_, err := sessionCol.UpdateOne(ctx,
+		bson.M{"_id": sid},
+		bson.M{
+			"$set": bson.M{
+				"userAgent":  c.Get(fiber.HeaderUserAgent),
+				"ip":         c.IP(),
+				"lastSeenAt": now,
+				"expiresAt":  now.Add(config.RefreshTokenTTL),
+			},
+			"$setOnInsert": bson.M{"authId": authID, "createdAt": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// endSession signs a single device out: its refresh tokens stop working and
+// its access tokens are rejected by AuthMiddleware.
+func endSession(ctx context.Context, authID primitive.ObjectID, sid string) error {
+	_, err := sessionCol.UpdateOne(ctx,
+		bson.M{"_id": sid, "authId": authID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if err := revokeRefreshFamily(ctx, sid); err != nil {
+		return err
+	}
+	return utils.RevokeSession(ctx, sid, authID)
+}
+
+// endOtherSessions signs every device of the account out except the one
+// holding session keepSID. Without a session to keep, all of them are.
+func endOtherSessions(ctx context.Context, authID primitive.ObjectID, keepSID string) error {
+	if keepSID == "" {
+		return revokeAllTokens(ctx, authID)
+	}
+	sids, err := refreshTokenCol.Distinct(ctx, "familyId", bson.M{
+		"authId":    authID,
+		"familyId":  bson.M{"$ne": keepSID},
+		"revokedAt": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+	for _, value := range sids {
+		if sid, ok := value.(string); ok {
+			if err := endSession(ctx, authID, sid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ListSessions shows the devices the account is signed in on, most recently
+// used first.
+func ListSessions(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+
+	cursor, err := sessionCol.Find(ctx, bson.M{
+		"authId":    authID,
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}, options.Find().SetSort(bson.D{{Key: "lastSeenAt", Value: -1}}))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to fetch sessions", map[string]string{"error": err.Error()})
+	}
+	var sessions []models.Session
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to fetch sessions", map[string]string{"error": err.Error()})
+	}
+
+	currentSID, _ := currentClaims(c)["sid"].(string)
+	result := make([]fiber.Map, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, fiber.Map{
+			"id":         session.ID,
+			"userAgent":  session.UserAgent,
+			"ip":         session.IP,
+			"createdAt":  session.CreatedAt,
+			"lastSeenAt": session.LastSeenAt,
+			"current":    session.ID == currentSID,
+		})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "success", fiber.Map{"data": result})
+}
+
+// DeleteSession signs one of the account's devices out.
+func DeleteSession(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+
+	var session models.Session
+	err = sessionCol.FindOne(ctx, bson.M{
+		"_id":       c.Params("id"),
+		"authId":    authID,
+		"revokedAt": bson.M{"$exists": false},
+	}).Decode(&session)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Session not found", nil)
+	}
+	if err := endSession(ctx, authID, session.ID); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to revoke session", map[string]string{"error": err.Error()})
+	}
+
+	email, _ := c.Locals("email").(string)
+	recordAudit(c, auditSessionRevoke, auditSuccess, authID, email, map[string]string{"sessionId": session.ID})
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Session revoked", nil)
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"user-auth-profile-service/src/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sessionView struct {
+	ID      string `json:"id"`
+	Current bool   `json:"current"`
+}
+
+func listSessions(t *testing.T, app *fiber.App, token string) []sessionView {
+	resp := sendJSON(t, app, http.MethodGet, "/auth/sessions", token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var data struct {
+		Data []sessionView `json:"data"`
+	}
+	decodeData(t, resp, &data)
+	return data.Data
+}
+
+func TestListSessions_MarksCurrent(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	first := login(t, app, user.Email, password)
+	login(t, app, user.Email, password)
+
+	sessions := listSessions(t, app, first.Token)
+	require.Len(t, sessions, 2)
+
+	claims, err := utils.ParseJWT(first.Token)
+	require.NoError(t, err)
+	for _, session := range sessions {
+		assert.Equal(t, session.ID == claims["sid"], session.Current)
+	}
+}
+
+func TestDeleteSession_SignsDeviceOut(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	current := login(t, app, user.Email, password)
+	other := login(t, app, user.Email, password)
+
+	claims, err := utils.ParseJWT(other.Token)
+	require.NoError(t, err)
+	sid, _ := claims["sid"].(string)
+
+	resp := sendJSON(t, app, http.MethodDelete, "/auth/sessions/"+sid, current.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = sendJSON(t, app, http.MethodGet, "/auth/sessions", other.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = refresh(t, app, other.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	sessions := listSessions(t, app, current.Token)
+	require.Len(t, sessions, 1)
+	assert.True(t, sessions[0].Current)
+}
+
+func TestDeleteSession_OtherAccount(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	other, otherPassword := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+	otherTokens := login(t, app, other.Email, otherPassword)
+
+	claims, err := utils.ParseJWT(otherTokens.Token)
+	require.NoError(t, err)
+	sid, _ := claims["sid"].(string)
+
+	resp := sendJSON(t, app, http.MethodDelete, "/auth/sessions/"+sid, tokens.Token, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp = refresh(t, app, otherTokens.RefreshToken)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/rbac"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var refreshTokenCol = configs.GetCollection(configs.DB, "refresh_tokens")
+
+var errAccountDisabled = errors.New("account is disabled")
+
+// issueTokenPair signs a new access token for user and stores a fresh refresh
+// token. An empty familyID starts a new session and refresh token family (a
+// new login), otherwise the token continues an existing family (a rotation).
+// The family ID doubles as the session ID.
+func issueTokenPair(ctx context.Context, c *fiber.Ctx, user models.Auth, familyID string) (fiber.Map, error) {
+	if user.Disabled {
+		return nil, errAccountDisabled
+	}
+
+	refreshToken, err := utils.GenerateOpaqueToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	if familyID == "" {
+		// Logging in is how a user takes back a deletion request
+		if err := cancelAccountDeletion(ctx, user); err != nil {
+			return nil, fmt.Errorf("failed to cancel account deletion: %w", err)
+		}
+		familyID, err = startSession(ctx, c, user)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start session: %w", err)
+		}
+	} else if err := touchSession(ctx, c, user.ID, familyID); err != nil {
+		log.Printf("⚠️ Failed to update session %s: %v", familyID, err)
+	}
+
+	roles := utils.AccountRoles(user)
+	accessToken, accessExpiresAt, err := utils.GenerateAccessToken(user.ID.Hex(), user.Email, familyID, roles, rbac.Permissions(roles, user.Permissions))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	now := time.Now()
+	_, err = refreshTokenCol.InsertOne(ctx, models.RefreshToken{
+		AuthID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(refreshToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(config.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return fiber.Map{
+		"token":        accessToken,
+		"tokenType":    "Bearer",
+		"expiresIn":    int(time.Until(accessExpiresAt).Seconds()),
+		"refreshToken": refreshToken,
+	}, nil
+}
+
+// completeLogin finishes a successful first-factor login. Accounts with
+// two-factor authentication get a challenge token instead of real tokens.
+func completeLogin(ctx context.Context, c *fiber.Ctx, user models.Auth, method string) error {
+	if user.Disabled {
+		recordLoginEvent(c, user.ID, user.Email, method, false, "account disabled")
+		return responses.SendErrorResponse(c, fiber.StatusForbidden, responses.ErrCodeForbidden, "Account disabled", nil)
+	}
+	if user.MFAEnabled {
+		challengeToken, err := startMFAChallenge(ctx, user)
+		if err != nil {
+			return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to start two-factor challenge", map[string]string{"error": err.Error()})
+		}
+		return responses.SendSuccessResponse(c, fiber.StatusOK, "mfa_required", fiber.Map{
+			"mfaRequired":    true,
+			"challengeToken": challengeToken,
+			"expiresIn":      int(config.MFAChallengeTTL.Seconds()),
+		})
+	}
+
+	return finishLogin(ctx, c, user, method)
+}
+
+// finishLogin issues the tokens of a new login once every factor has been
+// checked, and records it in the login history.
+func finishLogin(ctx context.Context, c *fiber.Ctx, user models.Auth, method string) error {
+	tokens, err := issueTokenPair(ctx, c, user, "")
+	if errors.Is(err, errAccountDisabled) {
+		recordLoginEvent(c, user.ID, user.Email, method, false, "account disabled")
+		return responses.SendErrorResponse(c, fiber.StatusForbidden, responses.ErrCodeForbidden, "Account disabled", nil)
+	}
+	if errors.Is(err, errAccountBeingPurged) {
+		recordLoginEvent(c, user.ID, user.Email, method, false, "account being deleted")
+		return responses.SendErrorResponse(c, fiber.StatusConflict, responses.ErrCodeConflict, "Account is being deleted, try again in a few minutes", nil)
+	}
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to issue tokens", map[string]string{"error": err.Error()})
+	}
+	recordLoginEvent(c, user.ID, user.Email, method, true, "")
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Login successful", tokens)
+}
+
+// revokeRefreshFamily revokes every refresh token that belongs to familyID.
+func revokeRefreshFamily(ctx context.Context, familyID string) error {
+	_, err := refreshTokenCol.UpdateMany(ctx,
+		bson.M{"familyId": familyID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	return err
+}
+
+func RefreshToken(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	tokenHash := utils.HashToken(req.RefreshToken)
+	now := time.Now()
+
+	// Claim the token atomically so two concurrent refreshes can't both rotate it
+	var current models.RefreshToken
+	err := refreshTokenCol.FindOneAndUpdate(ctx, bson.M{
+		"tokenHash": tokenHash,
+		"rotatedAt": bson.M{"$exists": false},
+		"revokedAt": bson.M{"$exists": false},
+		"expiresAt": bson.M{"$gt": now},
+	}, bson.M{"$set": bson.M{"rotatedAt": now}}).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		// A token that was already rotated is being replayed: either the client
+		// or an attacker holds a stolen copy, so kill the whole family.
+		var used models.RefreshToken
+		if refreshTokenCol.FindOne(ctx, bson.M{"tokenHash": tokenHash}).Decode(&used) == nil && used.RotatedAt != nil {
+			if err := revokeRefreshFamily(ctx, used.FamilyID); err != nil {
+				log.Printf("❌ Failed to revoke refresh token family %s: %v", used.FamilyID, err)
+			}
+			log.Printf("🚨 Refresh token reuse detected, revoked family %s", used.FamilyID)
+			return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Refresh token reuse detected", nil)
+		}
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid or expired refresh token", nil)
+	}
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to refresh token", map[string]string{"error": err.Error()})
+	}
+
+	var user models.Auth
+	if err := authCol.FindOne(ctx, bson.M{"_id": current.AuthID}).Decode(&user); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "User not found", nil)
+	}
+
+	tokens, err := issueTokenPair(ctx, c, user, current.FamilyID)
+	if errors.Is(err, errAccountDisabled) {
+		return responses.SendErrorResponse(c, fiber.StatusForbidden, responses.ErrCodeForbidden, "Account disabled", nil)
+	}
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to refresh token", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Token refreshed", tokens)
+}
+
+func Logout(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+
+	var req structure.LogoutRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+		}
+	}
+
+	claims := currentClaims(c)
+	jti, _ := claims["jti"].(string)
+	expiresAt, _ := claims.GetExpirationTime()
+	if expiresAt == nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	if err := utils.RevokeAccessToken(ctx, jti, authID, expiresAt.Time); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to logout", map[string]string{"error": err.Error()})
+	}
+
+	// Close the rest of this session too. Tokens from before sessions were
+	// recorded only know their refresh token family if the client sent it.
+	if sid, _ := claims["sid"].(string); sid != "" {
+		if err := endSession(ctx, authID, sid); err != nil {
+			return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to logout", map[string]string{"error": err.Error()})
+		}
+	} else if req.RefreshToken != "" {
+		var stored models.RefreshToken
+		err := refreshTokenCol.FindOne(ctx, bson.M{"tokenHash": utils.HashToken(req.RefreshToken), "authId": authID}).Decode(&stored)
+		if err == nil {
+			if err := revokeRefreshFamily(ctx, stored.FamilyID); err != nil {
+				return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to logout", map[string]string{"error": err.Error()})
+			}
+		}
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Logged out successfully", nil)
+}
+
+func LogoutAll(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+
+	if err := revokeAllTokens(ctx, authID); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to logout from all devices", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Logged out from all devices", nil)
+}
+
+// revokeAllTokens invalidates every session, access and refresh token of an
+// account.
+func revokeAllTokens(ctx context.Context, authID primitive.ObjectID) error {
+	if err := utils.RevokeAllAccessTokens(ctx, authID); err != nil {
+		return err
+	}
+	notRevoked := bson.M{"authId": authID, "revokedAt": bson.M{"$exists": false}}
+	revoke := bson.M{"$set": bson.M{"revokedAt": time.Now()}}
+	if _, err := refreshTokenCol.UpdateMany(ctx, notRevoked, revoke); err != nil {
+		return err
+	}
+	_, err := sessionCol.UpdateMany(ctx, notRevoked, revoke)
+	return err
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRefreshToken_Rotates(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	resp := refresh(t, app, tokens.RefreshToken)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	var rotated tokenPair
+	decodeData(t, resp, &rotated)
+	assert.NotEqual(t, tokens.RefreshToken, rotated.RefreshToken)
+
+	// The new pair belongs to the same session
+	oldClaims, err := utils.ParseJWT(tokens.Token)
+	require.NoError(t, err)
+	newClaims, err := utils.ParseJWT(rotated.Token)
+	require.NoError(t, err)
+	assert.Equal(t, oldClaims["sid"], newClaims["sid"])
+
+	resp = refresh(t, app, rotated.RefreshToken)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestRefreshToken_ReuseRevokesFamily(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	resp := refresh(t, app, tokens.RefreshToken)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var rotated tokenPair
+	decodeData(t, resp, &rotated)
+
+	// Replaying the rotated token kills the whole family
+	resp = refresh(t, app, tokens.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	var res responses.Response
+	json.NewDecoder(resp.Body).Decode(&res)
+	assert.Contains(t, res.Message, "reuse detected")
+
+	resp = refresh(t, app, rotated.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	count, err := refreshTokenCol.CountDocuments(context.TODO(), bson.M{"authId": user.ID, "revokedAt": bson.M{"$exists": false}})
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestRefreshToken_Unknown(t *testing.T) {
+	app := setupAuthApp()
+
+	resp := refresh(t, app, "not-a-refresh-token")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestRefreshToken_DisabledAccount(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	_, err := authCol.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"disabled": true}})
+	require.NoError(t, err)
+
+	resp := refresh(t, app, tokens.RefreshToken)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestLogout_RevokesSession(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	resp := sendJSON(t, app, http.MethodPost, "/auth/logout", tokens.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Neither the access token nor the refresh token work anymore
+	resp = sendJSON(t, app, http.MethodGet, "/auth/sessions", tokens.Token, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = refresh(t, app, tokens.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestLogout_KeepsOtherSessions(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	first := login(t, app, user.Email, password)
+	second := login(t, app, user.Email, password)
+
+	resp := sendJSON(t, app, http.MethodPost, "/auth/logout", first.Token, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = sendJSON(t, app, http.MethodGet, "/auth/sessions", second.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = refresh(t, app, second.RefreshToken)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestLogoutAll_RevokesEverySession(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	first := login(t, app, user.Email, password)
+	second := login(t, app, user.Email, password)
+
+	resp := sendJSON(t, app, http.MethodPost, "/auth/logout-all", first.Token, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	for _, tokens := range []tokenPair{first, second} {
+		resp = sendJSON(t, app, http.MethodGet, "/auth/sessions", tokens.Token, nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp = refresh(t, app, tokens.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	count, err := sessionCol.CountDocuments(context.TODO(), bson.M{"authId": user.ID, "revokedAt": bson.M{"$exists": false}})
+	require.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestUpdatePassword_SignsOutOtherSessions(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	current := login(t, app, user.Email, password)
+	other := login(t, app, user.Email, password)
+
+	resp := sendJSON(t, app, http.MethodPatch, "/auth/update-password", current.Token, structure.UpdatePasswordRequest{
+		CurrentPassword: password,
+		NewPassword:     randomPassword(),
+	})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = refresh(t, app, current.RefreshToken)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = refresh(t, app, other.RefreshToken)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestUpdatePassword_RejectsOtherAccountsEmail(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	victim, _ := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	resp := sendJSON(t, app, http.MethodPatch, "/auth/update-password", tokens.Token, structure.UpdatePasswordRequest{
+		Email:           victim.Email,
+		CurrentPassword: password,
+		NewPassword:     randomPassword(),
+	})
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	stored, err := findAuthByID(context.TODO(), victim.ID)
+	require.NoError(t, err)
+	assert.Equal(t, victim.Password, stored.Password)
+}
+
+func TestLogin_RecordsSession(t *testing.T) {
+	app := setupAuthApp()
+	user, password := createVerifiedUser(t)
+	tokens := login(t, app, user.Email, password)
+
+	claims, err := utils.ParseJWT(tokens.Token)
+	require.NoError(t, err)
+	var session models.Session
+	err = sessionCol.FindOne(context.TODO(), bson.M{"_id": claims["sid"], "authId": user.ID}).Decode(&session)
+	assert.NoError(t, err)
+}
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 	"user-auth-profile-service/src/configs"
 	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/rbac"
 	"user-auth-profile-service/src/responses"
 	"user-auth-profile-service/src/utils"
 
@@ -27,16 +29,22 @@ func CreateUser(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	// The profile always uses the account's email
+	email, _ := c.Locals("email").(string)
+
 	name := c.FormValue("name")
-	email := c.FormValue("email")
 	location := c.FormValue("location")
 	title := c.FormValue("title")
 	address := c.FormValue("address")
 	linkedin := c.FormValue("linkedin")
 	twitter := c.FormValue("twitter")
 	dob := c.FormValue("dob")
+	username := c.FormValue("username")
 	fileHeader, err := c.FormFile("resume")
-	username := c.Locals("username").(string)
 
 	if err != nil {
 		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Resume file is required", map[string]string{"error": err.Error()})
@@ -55,6 +63,7 @@ func CreateUser(c *fiber.Ctx) error {
 	var user models.User
 	err = userCollection.FindOne(ctx, bson.M{
 		"$or": []bson.M{
+			{"authId": authID},
 			{"email": email},
 			{"username": username},
 		}}).Decode(&user)
@@ -70,6 +79,7 @@ func CreateUser(c *fiber.Ctx) error {
 
 	newUser := models.User{
 		Id:       primitive.NewObjectID(),
+		AuthID:   authID,
 		Name:     name,
 		Email:    email,
 		Location: location,
@@ -100,6 +110,82 @@ func CreateUser(c *fiber.Ctx) error {
 	return responses.SendSuccessResponse(c, http.StatusCreated, "User created successfully", fiber.Map{"data": result})
 }
 
+// ownsProfile reports whether profile belongs to the caller. Profiles created
+// before they were linked to an account are matched by email.
+func ownsProfile(c *fiber.Ctx, profile models.User) bool {
+	if !profile.AuthID.IsZero() {
+		authID, err := currentAuthID(c)
+		return err == nil && profile.AuthID == authID
+	}
+	email, _ := c.Locals("email").(string)
+	return email != "" && strings.EqualFold(profile.Email, email)
+}
+
+// canModifyProfile reports whether the caller owns profile, or may change
+// every profile.
+func canModifyProfile(c *fiber.Ctx, profile models.User) bool {
+	return ownsProfile(c, profile) || hasPermission(c, rbac.PermUsersWrite)
+}
+
+// findOwnProfile returns the caller's profile, linking legacy profiles found
+// by email to the account on the way.
+func findOwnProfile(ctx context.Context, c *fiber.Ctx) (models.User, error) {
+	var profile models.User
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return profile, err
+	}
+
+	err = userCollection.FindOne(ctx, bson.M{"authId": authID}).Decode(&profile)
+	if err != mongo.ErrNoDocuments {
+		return profile, err
+	}
+
+	email, _ := c.Locals("email").(string)
+	err = userCollection.FindOne(ctx, bson.M{"email": email, "authId": bson.M{"$exists": false}}).Decode(&profile)
+	if err != nil {
+		return profile, err
+	}
+	if _, err := userCollection.UpdateOne(ctx, bson.M{"id": profile.Id}, bson.M{"$set": bson.M{"authId": authID}}); err != nil {
+		log.Printf("⚠️ Failed to link profile %s to account %s: %v", profile.Id.Hex(), authID.Hex(), err)
+	}
+	profile.AuthID = authID
+	return profile, nil
+}
+
+func GetMyProfile(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	profile, err := findOwnProfile(ctx, c)
+	if err != nil {
+		return responses.SendErrorResponse(c, http.StatusNotFound, responses.ErrCodeNotFound, "You have no profile yet", nil)
+	}
+	return responses.SendSuccessResponse(c, http.StatusOK, "success", fiber.Map{"data": profile})
+}
+
+func EditMyProfile(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	profile, err := findOwnProfile(ctx, c)
+	if err != nil {
+		return responses.SendErrorResponse(c, http.StatusNotFound, responses.ErrCodeNotFound, "You have no profile yet", nil)
+	}
+	return updateProfile(ctx, c, profile)
+}
+
+func DeleteMyProfile(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	profile, err := findOwnProfile(ctx, c)
+	if err != nil {
+		return responses.SendErrorResponse(c, http.StatusNotFound, responses.ErrCodeNotFound, "You have no profile yet", nil)
+	}
+	return deleteProfile(ctx, c, profile)
+}
+
 func GetAUser(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	userId := c.Params("userId")
@@ -116,22 +202,61 @@ func GetAUser(c *fiber.Ctx) error {
 	return responses.SendSuccessResponse(c, http.StatusOK, "success", fiber.Map{"data": user})
 }
 
+// GetUserByEmail looks a profile up by its email, for services that only
+// know the user's address.
+func GetUserByEmail(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	email := c.Query("email")
+	if email == "" {
+		return responses.SendValidationError(c, map[string]string{"email": "email query parameter is required"})
+	}
+
+	var user models.User
+	if err := userCollection.FindOne(ctx, bson.M{"email": email}).Decode(&user); err != nil {
+		return responses.SendErrorResponse(c, http.StatusNotFound, responses.ErrCodeNotFound, "User does not exist", nil)
+	}
+
+	return responses.SendSuccessResponse(c, http.StatusOK, "success", fiber.Map{"data": user})
+}
+
 func EditAUser(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	userId := c.Params("userId")
-	var user models.User
 
 	objId, err := primitive.ObjectIDFromHex(userId)
 	if err != nil {
 		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid user ID", map[string]string{"error": err.Error()})
 	}
 
+	var existing models.User
+	if err := userCollection.FindOne(ctx, bson.M{"id": objId}).Decode(&existing); err != nil {
+		return responses.SendErrorResponse(c, http.StatusNotFound, responses.ErrCodeNotFound, "User does not exist", nil)
+	}
+	if !canModifyProfile(c, existing) {
+		return responses.SendErrorResponse(c, fiber.StatusForbidden, responses.ErrCodeForbidden, "You can only edit your own profile", nil)
+	}
+
+	return updateProfile(ctx, c, existing)
+}
+
+// updateProfile applies the request body to profile. Callers check that the
+// caller may change it.
+func updateProfile(ctx context.Context, c *fiber.Ctx, profile models.User) error {
+	objId := profile.Id
+	var user models.User
+
 	if err := c.BodyParser(&user); err != nil {
 		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Failed to parse body", map[string]string{"error": err.Error()})
 	}
 
+	// Only the fields below can be edited, the email follows the account
+	user.Email = profile.Email
+	user.Username = profile.Username
+
 	if validationErr := validate.Struct(&user); validationErr != nil {
 		validationErrors := make(map[string]string)
 		if ve, ok := validationErr.(validator.ValidationErrors); ok {
@@ -173,7 +298,7 @@ func EditAUser(c *fiber.Ctx) error {
 		"dob":      user.DOB,
 	}
 	if resumeURL != "" {
-		update["resume_url"] = resumeURL
+		update["resume"] = resumeURL
 	}
 
 	result, err := userCollection.UpdateOne(ctx, bson.M{"id": objId}, bson.M{"$set": update})
@@ -189,6 +314,7 @@ func EditAUser(c *fiber.Ctx) error {
 		}
 	}
 
+	recordAudit(c, auditProfileUpdate, auditSuccess, profile.AuthID, profile.Email, map[string]string{"profileId": objId.Hex()})
 	return responses.SendSuccessResponse(c, fiber.StatusOK, "User updated successfully", fiber.Map{"data": updatedUser})
 }
 
@@ -199,7 +325,19 @@ func DeleteAUser(c *fiber.Ctx) error {
 
 	objId, _ := primitive.ObjectIDFromHex(userId)
 
-	result, err := userCollection.DeleteOne(ctx, bson.M{"id": objId})
+	var existing models.User
+	if err := userCollection.FindOne(ctx, bson.M{"id": objId}).Decode(&existing); err != nil {
+		return responses.SendErrorResponse(c, http.StatusNotFound, responses.ErrCodeNotFound, "User with specified ID not found!", nil)
+	}
+	if !canModifyProfile(c, existing) {
+		return responses.SendErrorResponse(c, fiber.StatusForbidden, responses.ErrCodeForbidden, "You can only delete your own profile", nil)
+	}
+
+	return deleteProfile(ctx, c, existing)
+}
+
+func deleteProfile(ctx context.Context, c *fiber.Ctx, profile models.User) error {
+	result, err := userCollection.DeleteOne(ctx, bson.M{"id": profile.Id})
 	if err != nil {
 		return responses.SendErrorResponse(c, http.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to delete user", map[string]string{"error": err.Error()})
 	}
@@ -208,6 +346,7 @@ func DeleteAUser(c *fiber.Ctx) error {
 		return responses.SendErrorResponse(c, http.StatusNotFound, responses.ErrCodeNotFound, "User with specified ID not found!", nil)
 	}
 
+	recordAudit(c, auditProfileDelete, auditSuccess, profile.AuthID, profile.Email, map[string]string{"profileId": profile.Id.Hex()})
 	return responses.SendSuccessResponse(c, http.StatusOK, "User successfully deleted", nil)
 }
 
@@ -224,6 +363,7 @@ func DeleteAllUsers(c *fiber.Ctx) error {
         return responses.SendErrorResponse(c, http.StatusNotFound, responses.ErrCodeNotFound, "No users found to delete!", nil)
     }
 
+    recordAudit(c, auditAdminDeleteAllProfiles, auditSuccess, primitive.NilObjectID, "", map[string]string{"count": fmt.Sprint(result.DeletedCount)})
     return responses.SendSuccessResponse(c, http.StatusOK, "All users successfully deleted", fiber.Map{"count": result.DeletedCount})
 }
 
@@ -0,0 +1,332 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/responses"
+	"user-auth-profile-service/src/structure"
+	"user-auth-profile-service/src/webauthn"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+	ceremonyTimeout      = 5 * time.Minute
+)
+
+var (
+	webauthnSessionCol = configs.GetCollection(configs.DB, "webauthn_sessions")
+	relyingParty       = webauthn.Config{
+		RPID:    config.WebAuthnRPID,
+		RPName:  config.WebAuthnRPName,
+		Origins: config.WebAuthnOrigins,
+		Timeout: ceremonyTimeout,
+	}
+)
+
+// toWebAuthnCredential converts a stored credential for the webauthn package.
+func toWebAuthnCredential(stored models.WebAuthnCredential) (webauthn.Credential, error) {
+	id, err := base64.RawURLEncoding.DecodeString(stored.CredentialID)
+	if err != nil {
+		return webauthn.Credential{}, err
+	}
+	return webauthn.Credential{ID: id, PublicKey: stored.PublicKey, SignCount: stored.SignCount}, nil
+}
+
+func toWebAuthnCredentials(stored []models.WebAuthnCredential) []webauthn.Credential {
+	creds := make([]webauthn.Credential, 0, len(stored))
+	for _, s := range stored {
+		if cred, err := toWebAuthnCredential(s); err == nil {
+			creds = append(creds, cred)
+		}
+	}
+	return creds
+}
+
+// consumeWebAuthnSession loads and deletes a ceremony session, so every
+// challenge can be answered only once.
+func consumeWebAuthnSession(ctx context.Context, id string, ceremony string) (models.WebAuthnSession, error) {
+	var session models.WebAuthnSession
+	err := webauthnSessionCol.FindOneAndDelete(ctx, bson.M{
+		"_id":       id,
+		"ceremony":  ceremony,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&session)
+	return session, err
+}
+
+func BeginWebAuthnRegistration(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "User not found", nil)
+	}
+
+	// The account ID doubles as user handle, it carries no personal data
+	options, err := relyingParty.BeginRegistration(webauthn.User{
+		ID:          user.ID[:],
+		Name:        user.Email,
+		DisplayName: user.Email,
+	}, toWebAuthnCredentials(user.WebAuthnCredentials))
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to start registration", map[string]string{"error": err.Error()})
+	}
+
+	session := models.WebAuthnSession{
+		ID:        uuid.New().String(),
+		Ceremony:  ceremonyRegistration,
+		Challenge: options.Challenge,
+		AuthID:    authID,
+		ExpiresAt: time.Now().Add(ceremonyTimeout),
+	}
+	if _, err := webauthnSessionCol.InsertOne(ctx, session); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to start registration", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Registration started", fiber.Map{
+		"sessionId": session.ID,
+		"publicKey": options,
+	})
+}
+
+func FinishWebAuthnRegistration(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.WebAuthnRegisterFinishRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+
+	session, err := consumeWebAuthnSession(ctx, req.SessionID, ceremonyRegistration)
+	if err != nil || session.AuthID != authID {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Registration session is invalid or expired", nil)
+	}
+
+	cred, err := relyingParty.FinishRegistration(session.Challenge, req.Credential)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Passkey registration failed", map[string]string{"error": err.Error()})
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "Passkey"
+	}
+	stored := models.WebAuthnCredential{
+		CredentialID:   base64.RawURLEncoding.EncodeToString(cred.ID),
+		Name:           name,
+		PublicKey:      cred.PublicKey,
+		SignCount:      cred.SignCount,
+		AAGUID:         cred.AAGUID,
+		Transports:     req.Credential.Response.Transports,
+		BackupEligible: cred.BackupEligible,
+		CreatedAt:      time.Now(),
+	}
+
+	_, err = authCol.UpdateOne(ctx, bson.M{"_id": authID}, bson.M{"$push": bson.M{"webauthnCredentials": stored}})
+	if mongo.IsDuplicateKeyError(err) {
+		return responses.SendErrorResponse(c, fiber.StatusConflict, responses.ErrCodeDuplicate, "Passkey already registered", nil)
+	}
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to save passkey", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusCreated, "Passkey registered successfully", fiber.Map{"credential": stored})
+}
+
+func BeginWebAuthnLogin(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.WebAuthnLoginBeginRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+		}
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	// Every login asks for a discoverable passkey. The email isn't looked up,
+	// listing an account's credentials would tell anyone which emails have an
+	// account and whether it has passkeys.
+	options, err := relyingParty.BeginLogin(nil)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to start login", map[string]string{"error": err.Error()})
+	}
+
+	session := models.WebAuthnSession{
+		ID:        uuid.New().String(),
+		Ceremony:  ceremonyLogin,
+		Challenge: options.Challenge,
+		ExpiresAt: time.Now().Add(ceremonyTimeout),
+	}
+	if _, err := webauthnSessionCol.InsertOne(ctx, session); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to start login", map[string]string{"error": err.Error()})
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Login started", fiber.Map{
+		"sessionId": session.ID,
+		"publicKey": options,
+	})
+}
+
+func FinishWebAuthnLogin(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var req structure.WebAuthnLoginFinishRequest
+	if err := c.BodyParser(&req); err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Invalid request format", map[string]string{"error": err.Error()})
+	}
+	if err := authValidate.Struct(req); err != nil {
+		validationErrors := make(map[string]string)
+		if ve, ok := err.(validator.ValidationErrors); ok {
+			for _, e := range ve {
+				validationErrors[e.Field()] = fmt.Sprintf("validation failed on '%s' tag", e.Tag())
+			}
+		}
+		return responses.SendValidationError(c, validationErrors)
+	}
+
+	session, err := consumeWebAuthnSession(ctx, req.SessionID, ceremonyLogin)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusBadRequest, responses.ErrCodeBadRequest, "Login session is invalid or expired", nil)
+	}
+
+	credentialID := base64.RawURLEncoding.EncodeToString(req.Credential.RawID)
+	var user models.Auth
+	if err := authCol.FindOne(ctx, bson.M{"webauthnCredentials.credentialId": credentialID}).Decode(&user); err != nil {
+		recordLoginEvent(c, primitive.NilObjectID, "", loginMethodWebAuthn, false, "unknown passkey")
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Unknown passkey", nil)
+	}
+	if userHandle := req.Credential.Response.UserHandle; len(userHandle) > 0 && !bytes.Equal(userHandle, user.ID[:]) {
+		recordLoginEvent(c, user.ID, user.Email, loginMethodWebAuthn, false, "user handle mismatch")
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Unknown passkey", nil)
+	}
+	if !user.IsVerified {
+		recordLoginEvent(c, user.ID, user.Email, loginMethodWebAuthn, false, "email not verified")
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Email not verified", nil)
+	}
+
+	var stored models.WebAuthnCredential
+	for _, cred := range user.WebAuthnCredentials {
+		if cred.CredentialID == credentialID {
+			stored = cred
+			break
+		}
+	}
+	cred, err := toWebAuthnCredential(stored)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Stored passkey is corrupt", nil)
+	}
+
+	assertion, err := relyingParty.FinishLogin(session.Challenge, req.Credential, cred)
+	if err != nil {
+		recordLoginEvent(c, user.ID, user.Email, loginMethodWebAuthn, false, "passkey verification failed")
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Passkey verification failed", map[string]string{"error": err.Error()})
+	}
+
+	_, err = authCol.UpdateOne(ctx,
+		bson.M{"_id": user.ID, "webauthnCredentials.credentialId": credentialID},
+		bson.M{"$set": bson.M{
+			"webauthnCredentials.$.signCount":  assertion.SignCount,
+			"webauthnCredentials.$.lastUsedAt": time.Now(),
+		}},
+	)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to update passkey", map[string]string{"error": err.Error()})
+	}
+
+	// A passkey that verified the user (PIN or biometric) already counts as
+	// two factors. A security key that only proved presence is one factor, so
+	// accounts with two-factor authentication still get the TOTP challenge.
+	if !assertion.UserVerified {
+		return completeLogin(ctx, c, user, loginMethodWebAuthn)
+	}
+	return finishLogin(ctx, c, user, loginMethodWebAuthn)
+}
+
+func ListWebAuthnCredentials(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+	user, err := findAuthByID(ctx, authID)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "User not found", nil)
+	}
+
+	credentials := user.WebAuthnCredentials
+	if credentials == nil {
+		credentials = []models.WebAuthnCredential{}
+	}
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "success", fiber.Map{"credentials": credentials})
+}
+
+func DeleteWebAuthnCredential(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	authID, err := currentAuthID(c)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusUnauthorized, responses.ErrCodeUnauthorized, "Invalid token claims", nil)
+	}
+
+	credentialID := c.Params("credentialId")
+	result, err := authCol.UpdateOne(ctx,
+		bson.M{"_id": authID, "webauthnCredentials.credentialId": credentialID},
+		bson.M{"$pull": bson.M{"webauthnCredentials": bson.M{"credentialId": credentialID}}},
+	)
+	if err != nil {
+		return responses.SendErrorResponse(c, fiber.StatusInternalServerError, responses.ErrCodeInternalError, "Failed to remove passkey", map[string]string{"error": err.Error()})
+	}
+	if result.MatchedCount == 0 {
+		return responses.SendErrorResponse(c, fiber.StatusNotFound, responses.ErrCodeNotFound, "Passkey not found", nil)
+	}
+
+	return responses.SendSuccessResponse(c, fiber.StatusOK, "Passkey removed", nil)
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"strings"
+
+	"user-auth-profile-service/src/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// JWKS publishes the public signing keys so that other services can verify our
+// tokens without sharing a secret. The set is returned as-is rather than in the
+// usual response envelope because JWKS clients expect the standard format.
+func JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(utils.JWKS())
+}
+
+// OpenIDConfiguration is the OpenID Connect discovery document.
+func OpenIDConfiguration(c *fiber.Ctx) error {
+	issuer := strings.TrimRight(config.JWTIssuer, "/")
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"userinfo_endpoint":                     issuer + "/userinfo",
+		"introspection_endpoint":                issuer + "/oauth/introspect",
+		"revocation_endpoint":                   issuer + "/oauth/revoke",
+		"jwks_uri":                              issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "client_credentials"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{utils.SigningAlgorithm()},
+		"scopes_supported":                      supportedScopes,
+		"claims_supported":                      []string{"sub", "email", "email_verified", "name", "preferred_username"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
@@ -0,0 +1,50 @@
+// Package lockout decides how failed login attempts are throttled. Every
+// failure doubles the wait before the next attempt, and after MaxFailures
+// failures the key is locked for LockDuration.
+package lockout
+
+import "time"
+
+type Policy struct {
+	MaxFailures  int
+	LockDuration time.Duration
+	// BaseDelay is the wait after the first failure. Zero disables backoff,
+	// so only the lock applies.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// Decision is what happens after a failed attempt.
+type Decision struct {
+	// Locked is set when this failure locked the key. The failure count
+	// starts over once the lock expires.
+	Locked        bool
+	NextAttemptAt time.Time
+}
+
+// Delay returns the backoff after the given number of consecutive failures.
+func (p Policy) Delay(failures int) time.Duration {
+	if p.BaseDelay <= 0 || failures <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// AfterFailure decides when the next attempt is allowed, given the number of
+// consecutive failures including the one that just happened.
+func (p Policy) AfterFailure(failures int, now time.Time) Decision {
+	if p.MaxFailures > 0 && failures >= p.MaxFailures {
+		return Decision{Locked: true, NextAttemptAt: now.Add(p.LockDuration)}
+	}
+	return Decision{NextAttemptAt: now.Add(p.Delay(failures))}
+}
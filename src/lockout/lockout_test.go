@@ -0,0 +1,37 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelay_DoublesUpToMax(t *testing.T) {
+	p := Policy{BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+
+	assert.Equal(t, time.Duration(0), p.Delay(0))
+	assert.Equal(t, time.Second, p.Delay(1))
+	assert.Equal(t, 2*time.Second, p.Delay(2))
+	assert.Equal(t, 8*time.Second, p.Delay(4))
+	assert.Equal(t, 10*time.Second, p.Delay(5))
+	assert.Equal(t, 10*time.Second, p.Delay(1000))
+}
+
+func TestDelay_ZeroBaseDisablesBackoff(t *testing.T) {
+	p := Policy{MaxFailures: 3, LockDuration: time.Minute}
+	assert.Equal(t, time.Duration(0), p.Delay(2))
+}
+
+func TestAfterFailure_LocksAtMaxFailures(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	p := Policy{MaxFailures: 3, LockDuration: 15 * time.Minute, BaseDelay: time.Second}
+
+	d := p.AfterFailure(2, now)
+	assert.False(t, d.Locked)
+	assert.Equal(t, now.Add(2*time.Second), d.NextAttemptAt)
+
+	d = p.AfterFailure(3, now)
+	assert.True(t, d.Locked)
+	assert.Equal(t, now.Add(15*time.Minute), d.NextAttemptAt)
+}
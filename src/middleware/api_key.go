@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"user-auth-profile-service/src/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// BlockAPIKeys keeps API keys away from credentials, sessions, the account
+// itself and OAuth approvals, so a leaked key can't be turned into a login.
+// It must run after AuthMiddleware.
+func BlockAPIKeys(c *fiber.Ctx) error {
+	claims, _ := c.Locals("claims").(jwt.MapClaims)
+	if claims["token_use"] == utils.TokenUseAPIKey {
+		return c.Status(403).JSON(fiber.Map{"error": "Not allowed with an API key"})
+	}
+	return c.Next()
+}
@@ -1,15 +1,28 @@
 package middleware
 
 import (
+	"errors"
 	"strings"
 	"time"
 
 	"user-auth-profile-service/src/utils"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 )
 
+// AuthMiddleware accepts the service's own access tokens and API keys.
 func AuthMiddleware(c *fiber.Ctx) error {
+	return authenticate(c, utils.TokenUseAccess)
+}
+
+// UserInfoMiddleware is AuthMiddleware for the OpenID Connect userinfo
+// endpoint, which also accepts the access tokens issued to OAuth clients.
+func UserInfoMiddleware(c *fiber.Ctx) error {
+	return authenticate(c, utils.TokenUseAccess, utils.TokenUseClientAccess)
+}
+
+func authenticate(c *fiber.Ctx, tokenUses ...string) error {
 	authHeader := c.Get("Authorization")
 	if authHeader == "" {
 		return c.Status(401).JSON(fiber.Map{"error": "No authorization header"})
@@ -20,11 +33,34 @@ func AuthMiddleware(c *fiber.Ctx) error {
 		return c.Status(401).JSON(fiber.Map{"error": "Invalid token format"})
 	}
 
-	claims, err := utils.ParseJWT(tokenString)
+	// API keys are sent like access tokens but checked against the database
+	if strings.HasPrefix(tokenString, utils.APIKeyPrefix) {
+		claims, err := utils.AuthenticateAPIKey(c.UserContext(), tokenString)
+		if errors.Is(err, utils.ErrInvalidAPIKey) {
+			return c.Status(401).JSON(fiber.Map{"error": "Invalid API key"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to check API key"})
+		}
+		return authenticated(c, claims)
+	}
+
+	claims, err := utils.ParseTypedJWT(tokenString, tokenUses...)
 	if err != nil {
 		return c.Status(401).JSON(fiber.Map{"error": "Invalid token"})
 	}
 
+	// Tokens issued to OAuth clients for a user were once plain access
+	// tokens. Only machine clients' own tokens may name a client here.
+	if claims["token_use"] == utils.TokenUseAccess && claims["gty"] != utils.GrantClientCredentials {
+		if _, ok := claims["client_id"]; ok {
+			return c.Status(401).JSON(fiber.Map{"error": "Invalid token"})
+		}
+		if _, ok := claims["scope"]; ok {
+			return c.Status(401).JSON(fiber.Map{"error": "Invalid token"})
+		}
+	}
+
 	// Check token expiration
 	if exp, ok := claims["exp"].(float64); ok {
 		if time.Now().Unix() > int64(exp) {
@@ -32,12 +68,54 @@ func AuthMiddleware(c *fiber.Ctx) error {
 		}
 	}
 
+	// Reject tokens that were revoked by a logout
+	revoked, err := utils.IsAccessTokenRevoked(c.UserContext(), claims)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check token status"})
+	}
+	if revoked {
+		return c.Status(401).JSON(fiber.Map{"error": "Token has been revoked"})
+	}
+
+	return authenticated(c, claims)
+}
+
+// authenticated stores the caller's identity for the protected route and
+// passes the request on.
+func authenticated(c *fiber.Ctx, claims jwt.MapClaims) error {
+	// Machine clients have no account, RequirePermission limits them to the
+	// scopes they were granted
+	if claims["gty"] == utils.GrantClientCredentials {
+		clientID, ok := claims["client_id"].(string)
+		if !ok || clientID == "" {
+			return c.Status(401).JSON(fiber.Map{"error": "Invalid token claims"})
+		}
+		c.Locals("clientId", clientID)
+		c.Locals("claims", claims)
+		return c.Next()
+	}
+
 	// Store email in context for use in protected routes
 	if email, ok := claims["email"].(string); ok {
 		c.Locals("email", email)
 	} else {
 		return c.Status(401).JSON(fiber.Map{"error": "Invalid token claims"})
 	}
+	sub, _ := claims["sub"].(string)
+	c.Locals("authId", sub)
+	c.Locals("claims", claims)
+
+	// authId and email are who the request acts as. realAuthId and realEmail
+	// are who sent it, which differ when an admin impersonates the account.
+	if actorID, actorEmail, ok := utils.Actor(claims); ok {
+		c.Locals("realAuthId", actorID)
+		c.Locals("realEmail", actorEmail)
+		c.Locals("impersonating", true)
+	} else {
+		c.Locals("realAuthId", sub)
+		c.Locals("realEmail", c.Locals("email"))
+		c.Locals("impersonating", false)
+	}
 
 	return c.Next()
 }
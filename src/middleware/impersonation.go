@@ -0,0 +1,12 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// BlockImpersonation keeps admins acting as another account away from
+// credentials and other sensitive settings. It must run after AuthMiddleware.
+func BlockImpersonation(c *fiber.Ctx) error {
+	if impersonating, _ := c.Locals("impersonating").(bool); impersonating {
+		return c.Status(403).JSON(fiber.Map{"error": "Not allowed while impersonating"})
+	}
+	return c.Next()
+}
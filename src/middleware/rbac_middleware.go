@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"user-auth-profile-service/src/rbac"
+	"user-auth-profile-service/src/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func claimStrings(c *fiber.Ctx, name string) []string {
+	claims, _ := c.Locals("claims").(jwt.MapClaims)
+	return utils.ClaimStrings(claims, name)
+}
+
+// RequireRole only lets accounts with role through. It must run after
+// AuthMiddleware.
+func RequireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !rbac.Has(claimStrings(c, "roles"), role) {
+			return c.Status(403).JSON(fiber.Map{"error": "Role " + role + " required"})
+		}
+		return c.Next()
+	}
+}
+
+// RequirePermission only lets accounts holding every one of perms through.
+// It must run after AuthMiddleware.
+func RequirePermission(perms ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted := claimStrings(c, "permissions")
+		for _, perm := range perms {
+			if !rbac.Has(granted, perm) {
+				return c.Status(403).JSON(fiber.Map{"error": "Missing permission " + perm})
+			}
+		}
+		return c.Next()
+	}
+}
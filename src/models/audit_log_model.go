@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditLog is one security relevant action. Entries are only ever inserted.
+// The actor is whoever made the request, the target the account it was
+// about. They differ for admin actions, including those an admin takes while
+// impersonating the target. Machine clients have no account and are recorded
+// by ActorClientID.
+type AuditLog struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Action        string             `bson:"action" json:"action"`
+	Outcome       string             `bson:"outcome" json:"outcome"`
+	ActorID       primitive.ObjectID `bson:"actorId,omitempty" json:"actorId,omitempty"`
+	ActorEmail    string             `bson:"actorEmail,omitempty" json:"actorEmail,omitempty"`
+	ActorClientID string             `bson:"actorClientId,omitempty" json:"actorClientId,omitempty"`
+	TargetID      primitive.ObjectID `bson:"targetId,omitempty" json:"targetId,omitempty"`
+	TargetEmail   string             `bson:"targetEmail,omitempty" json:"targetEmail,omitempty"`
+	IP            string             `bson:"ip" json:"ip"`
+	UserAgent     string             `bson:"userAgent" json:"userAgent"`
+	RequestID     string             `bson:"requestId" json:"requestId"`
+	Impersonation bool               `bson:"impersonation,omitempty" json:"impersonation,omitempty"`
+	Details       map[string]string  `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -7,12 +7,51 @@ import (
 )
 
 type Auth struct {
-	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Email        string             `bson:"email" json:"email" validate:"required,email"`
-	Password     string             `bson:"password" json:"password" validate:"required,min=8"`
-	IsVerified   bool               `bson:"isVerified" json:"isVerified"`
-	OTP          string             `bson:"otp,omitempty" json:"otp,omitempty"`
-	OTPExpiresAt time.Time          `bson:"otpExpiresAt" json:"otpExpiresAt"`
-	Token        string             `bson:"token,omitempty" json:"token,omitempty"`
-	ExpiresAt    time.Time          `bson:"expiresAt" json:"expiresAt"`
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Email      string             `bson:"email" json:"email" validate:"required,email"`
+	Password   string             `bson:"password" json:"password" validate:"required,min=8"`
+	IsVerified bool               `bson:"isVerified" json:"isVerified"`
+	// OTP is the hash of the email verification code
+	OTP          string    `bson:"otp,omitempty" json:"-"`
+	OTPExpiresAt time.Time `bson:"otpExpiresAt" json:"otpExpiresAt"`
+	OTPAttempts  int       `bson:"otpAttempts,omitempty" json:"-"`
+	OTPSentAt    time.Time `bson:"otpSentAt,omitempty" json:"-"`
+
+	// Hashes of previous passwords, newest first
+	PasswordHistory []string `bson:"passwordHistory,omitempty" json:"-"`
+
+	// Two-factor authentication (TOTP)
+	MFAEnabled           bool     `bson:"mfaEnabled" json:"mfaEnabled"`
+	TOTPSecret           string   `bson:"totpSecret,omitempty" json:"-"`
+	TOTPPendingSecret    string   `bson:"totpPendingSecret,omitempty" json:"-"`
+	TOTPLastStep         int64    `bson:"totpLastStep,omitempty" json:"-"`
+	RecoveryCodes        []string `bson:"recoveryCodes,omitempty" json:"-"`
+	MFAChallengeID       string   `bson:"mfaChallengeId,omitempty" json:"-"`
+	MFAChallengeAttempts int      `bson:"mfaChallengeAttempts,omitempty" json:"-"`
+
+	// Only the most recently requested magic link can be used
+	MagicLinkID string `bson:"magicLinkId,omitempty" json:"-"`
+
+	// Passkeys
+	WebAuthnCredentials []WebAuthnCredential `bson:"webauthnCredentials,omitempty" json:"-"`
+
+	// Roles and directly granted permissions, see package rbac. Accounts
+	// without roles get rbac.DefaultRoles.
+	Roles       []string `bson:"roles,omitempty" json:"roles,omitempty"`
+	Permissions []string `bson:"permissions,omitempty" json:"permissions,omitempty"`
+
+	// Linked social login accounts
+	Identities []ExternalIdentity `bson:"identities,omitempty" json:"-"`
+
+	// Disabled accounts can't sign in, see the admin API
+	Disabled       bool       `bson:"disabled,omitempty" json:"disabled"`
+	DisabledAt     *time.Time `bson:"disabledAt,omitempty" json:"disabledAt,omitempty"`
+	DisabledReason string     `bson:"disabledReason,omitempty" json:"disabledReason,omitempty"`
+
+	// Self-service deletion, the account is purged once DeleteAfter has passed
+	DeletionRequestedAt *time.Time `bson:"deletionRequestedAt,omitempty" json:"deletionRequestedAt,omitempty"`
+	DeleteAfter         *time.Time `bson:"deleteAfter,omitempty" json:"deleteAfter,omitempty"`
+	PurgeStartedAt      *time.Time `bson:"purgeStartedAt,omitempty" json:"-"`
+	PurgeFailedAt       *time.Time `bson:"purgeFailedAt,omitempty" json:"-"`
+	PurgeError          string     `bson:"purgeError,omitempty" json:"-"`
 }
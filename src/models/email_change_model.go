@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	EmailChangePending   = "pending"
+	EmailChangeConfirmed = "confirmed"
+	EmailChangeCancelled = "cancelled"
+	EmailChangeReverted  = "reverted"
+)
+
+// EmailChange tracks a request to move an account to a new email. The new
+// address confirms it with the confirm token, the old one can undo it with
+// the revert token until ExpiresAt.
+type EmailChange struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	AuthID           primitive.ObjectID `bson:"authId" json:"authId"`
+	OldEmail         string             `bson:"oldEmail" json:"oldEmail"`
+	NewEmail         string             `bson:"newEmail" json:"newEmail"`
+	Status           string             `bson:"status" json:"status"`
+	ConfirmTokenHash string             `bson:"confirmTokenHash" json:"-"`
+	RevertTokenHash  string             `bson:"revertTokenHash" json:"-"`
+	CreatedAt        time.Time          `bson:"createdAt" json:"createdAt"`
+	ConfirmExpiresAt time.Time          `bson:"confirmExpiresAt" json:"confirmExpiresAt"`
+	ExpiresAt        time.Time          `bson:"expiresAt" json:"expiresAt"`
+	ConfirmedAt      *time.Time         `bson:"confirmedAt,omitempty" json:"confirmedAt,omitempty"`
+	RevertedAt       *time.Time         `bson:"revertedAt,omitempty" json:"revertedAt,omitempty"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginAttempt counts recent failed logins for one key, an account email or
+// a client IP.
+type LoginAttempt struct {
+	ID            string     `bson:"_id" json:"id"`
+	Failures      int        `bson:"failures" json:"failures"`
+	LastFailureAt time.Time  `bson:"lastFailureAt" json:"lastFailureAt"`
+	NextAttemptAt time.Time  `bson:"nextAttemptAt" json:"nextAttemptAt"`
+	LockedUntil   *time.Time `bson:"lockedUntil,omitempty" json:"lockedUntil,omitempty"`
+	ExpiresAt     time.Time  `bson:"expiresAt" json:"expiresAt"`
+}
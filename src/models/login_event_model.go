@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginEvent is one sign-in attempt, kept for the admin login history.
+// AuthID is empty when the email doesn't belong to an account.
+type LoginEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	AuthID    primitive.ObjectID `bson:"authId,omitempty" json:"authId,omitempty"`
+	Email     string             `bson:"email" json:"email"`
+	Method    string             `bson:"method" json:"method"`
+	Success   bool               `bson:"success" json:"success"`
+	Reason    string             `bson:"reason,omitempty" json:"reason,omitempty"`
+	IP        string             `bson:"ip" json:"ip"`
+	UserAgent string             `bson:"userAgent" json:"userAgent"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"-"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OAuthClient is an application registered to sign users in through this
+// service. Public clients (SPAs, mobile apps) have no secret and rely on PKCE.
+// Machine clients are other services calling us for themselves through the
+// client credentials grant. Their Scopes are the permissions they may be
+// granted, see package rbac.
+type OAuthClient struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ClientID     string             `bson:"clientId" json:"clientId"`
+	SecretHash   string             `bson:"secretHash,omitempty" json:"-"`
+	Name         string             `bson:"name" json:"name"`
+	RedirectURIs []string           `bson:"redirectUris" json:"redirectUris"`
+	Scopes       []string           `bson:"scopes" json:"scopes"`
+	Public       bool               `bson:"public" json:"public"`
+	Machine      bool               `bson:"machine,omitempty" json:"machine"`
+	OwnerID      primitive.ObjectID `bson:"ownerId" json:"ownerId"`
+	CreatedAt    time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// AuthorizationCode is an issued, not yet redeemed authorization code. The
+// code itself is never stored, ID is its hash.
+type AuthorizationCode struct {
+	ID            string             `bson:"_id" json:"-"`
+	ClientID      string             `bson:"clientId" json:"clientId"`
+	AuthID        primitive.ObjectID `bson:"authId" json:"authId"`
+	RedirectURI   string             `bson:"redirectUri" json:"redirectUri"`
+	Scope         string             `bson:"scope" json:"scope"`
+	Nonce         string             `bson:"nonce,omitempty" json:"nonce,omitempty"`
+	CodeChallenge string             `bson:"codeChallenge" json:"-"`
+	AuthTime      time.Time          `bson:"authTime" json:"authTime"`
+	ExpiresAt     time.Time          `bson:"expiresAt" json:"expiresAt"`
+}
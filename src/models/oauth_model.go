@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ExternalIdentity links an auth account to an account at a social login
+// provider. Subject is the provider's stable user ID, never the email.
+type ExternalIdentity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"subject"`
+	Email    string    `bson:"email" json:"email"`
+	LinkedAt time.Time `bson:"linkedAt" json:"linkedAt"`
+}
+
+// OAuthState is a pending social login, stored between the redirect to the
+// provider and its callback. Nonce is checked against the ID token of OpenID
+// Connect providers.
+type OAuthState struct {
+	ID           string    `bson:"_id" json:"id"`
+	Provider     string    `bson:"provider" json:"provider"`
+	CodeVerifier string    `bson:"codeVerifier" json:"-"`
+	Nonce        string    `bson:"nonce" json:"-"`
+	ExpiresAt    time.Time `bson:"expiresAt" json:"expiresAt"`
+}
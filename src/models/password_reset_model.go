@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PasswordReset is a password reset token. The token sent to the user is
+// "<selector>:<verifier>"; the selector is the document ID and only a hash of
+// the verifier is stored, so a database leak can't be used to reset passwords.
+type PasswordReset struct {
+	ID           string             `bson:"_id" json:"-"`
+	AuthID       primitive.ObjectID `bson:"authId" json:"authId"`
+	VerifierHash string             `bson:"verifierHash" json:"-"`
+	// Active is unset once the token is used or replaced. A unique index on
+	// active tokens allows only one per account.
+	Active    bool       `bson:"active,omitempty" json:"active"`
+	CreatedAt time.Time  `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time  `bson:"expiresAt" json:"expiresAt"`
+	UsedAt    *time.Time `bson:"usedAt,omitempty" json:"usedAt,omitempty"`
+	UsedByIP  string     `bson:"usedByIp,omitempty" json:"usedByIp,omitempty"`
+	UsedByUA  string     `bson:"usedByUserAgent,omitempty" json:"usedByUserAgent,omitempty"`
+}
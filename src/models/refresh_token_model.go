@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is a single link in a refresh token family. Every refresh
+// rotates the token: the presented one is marked as rotated and a new one is
+// issued with the same FamilyID.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	AuthID    primitive.ObjectID `bson:"authId" json:"authId"`
+	FamilyID  string             `bson:"familyId" json:"familyId"`
+	TokenHash string             `bson:"tokenHash" json:"-"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+	RotatedAt *time.Time         `bson:"rotatedAt,omitempty" json:"rotatedAt,omitempty"`
+	RevokedAt *time.Time         `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RevokedToken marks access tokens that must no longer be accepted. A record
+// either names a single token by JTI (logout), every token of one session by
+// SessionID (signing a device out), or revokes every token issued to AuthID up
+// to ValidAfter (logout everywhere). Records only need to live as
+// long as the tokens they cover, so they are removed by a TTL index.
+type RevokedToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	JTI        string             `bson:"jti,omitempty" json:"jti,omitempty"`
+	SessionID  string             `bson:"sid,omitempty" json:"sid,omitempty"`
+	AuthID     primitive.ObjectID `bson:"authId" json:"authId"`
+	ValidAfter *time.Time         `bson:"validAfter,omitempty" json:"validAfter,omitempty"`
+	RevokedAt  time.Time          `bson:"revokedAt" json:"revokedAt"`
+	ExpiresAt  time.Time          `bson:"expiresAt" json:"expiresAt"`
+}
@@ -3,7 +3,9 @@ package models
 import "go.mongodb.org/mongo-driver/bson/primitive"
 
 type User struct {
-	Id       primitive.ObjectID `json:"id,omitempty"`
+	Id primitive.ObjectID `json:"id,omitempty"`
+	// AuthID links the profile to the auth account that owns it
+	AuthID   primitive.ObjectID `bson:"authId,omitempty" json:"authId,omitempty"`
 	Email    string             `json:"email,omitempty" validate:"required,email"`
 	Name     string             `json:"name,omitempty" validate:"required"`
 	Location string             `json:"location,omitempty" validate:"required"`
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WebAuthnCredential is a passkey registered to an auth account.
+type WebAuthnCredential struct {
+	// CredentialID is the base64url encoded credential ID
+	CredentialID   string     `bson:"credentialId" json:"id"`
+	Name           string     `bson:"name" json:"name"`
+	PublicKey      []byte     `bson:"publicKey" json:"-"`
+	SignCount      uint32     `bson:"signCount" json:"-"`
+	AAGUID         []byte     `bson:"aaguid,omitempty" json:"-"`
+	Transports     []string   `bson:"transports,omitempty" json:"transports,omitempty"`
+	BackupEligible bool       `bson:"backupEligible" json:"backupEligible"`
+	CreatedAt      time.Time  `bson:"createdAt" json:"createdAt"`
+	LastUsedAt     *time.Time `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+}
+
+// WebAuthnSession holds the challenge of a registration or login ceremony
+// between its begin and finish requests.
+type WebAuthnSession struct {
+	ID        string             `bson:"_id" json:"id"`
+	Ceremony  string             `bson:"ceremony" json:"ceremony"`
+	Challenge []byte             `bson:"challenge" json:"-"`
+	AuthID    primitive.ObjectID `bson:"authId,omitempty" json:"authId,omitempty"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
+}
@@ -0,0 +1,181 @@
+package oauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider is a minimal authorization server. It issues one code for the
+// challenge it was given and only redeems it with the matching verifier.
+type fakeProvider struct {
+	server    *httptest.Server
+	challenge string
+	nonce     string
+	// emailVerified is returned as email_verified by userinfo
+	emailVerified interface{}
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	f := &fakeProvider{emailVerified: true}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("code") != "good-code" || ChallengeS256(r.Form.Get("code_verifier")) != f.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at-123", "token_type": "Bearer", "id_token": f.idToken("abc", "client-1")})
+	})
+
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer at-123" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return true
+	}
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			json.NewEncoder(w).Encode(map[string]interface{}{"sub": "abc", "email": "jane@example.com", "email_verified": f.emailVerified, "name": "Jane"})
+		}
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 42, "login": "jane"})
+		}
+	})
+	mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"email": "old@example.com", "primary": false, "verified": true},
+				{"email": "jane@example.com", "primary": true, "verified": true},
+			})
+		}
+	})
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// idToken returns an unsigned ID token carrying the nonce of the last
+// authorization.
+func (f *fakeProvider) idToken(sub string, aud string) string {
+	claims, _ := json.Marshal(map[string]string{"sub": sub, "aud": aud, "nonce": f.nonce})
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(claims) + ".sig"
+}
+
+// authorize plays the browser leg: it checks the authorization URL and
+// remembers the challenge, as the real provider would.
+func (f *fakeProvider) authorize(t *testing.T, p Provider) string {
+	verifier, err := GenerateVerifier()
+	require.NoError(t, err)
+
+	u, err := url.Parse(p.AuthCodeURL("state-1", ChallengeS256(verifier), "nonce-1"))
+	require.NoError(t, err)
+	q := u.Query()
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, "client-1", q.Get("client_id"))
+	assert.Equal(t, "state-1", q.Get("state"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	f.challenge = q.Get("code_challenge")
+	f.nonce = q.Get("nonce")
+	return verifier
+}
+
+func TestOIDCExchange(t *testing.T) {
+	f := newFakeProvider(t)
+	p := NewOIDC("acme", Config{
+		ClientID:    "client-1",
+		RedirectURL: "http://localhost/callback",
+		AuthURL:     f.server.URL + "/authorize",
+		TokenURL:    f.server.URL + "/token",
+		UserInfoURL: f.server.URL + "/userinfo",
+	})
+	verifier := f.authorize(t, p)
+
+	identity, err := p.Exchange(context.Background(), "good-code", verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{Provider: "acme", Subject: "abc", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}, identity)
+}
+
+func TestGitHubExchange(t *testing.T) {
+	f := newFakeProvider(t)
+	p := NewGitHub(Config{
+		ClientID:    "client-1",
+		AuthURL:     f.server.URL + "/authorize",
+		TokenURL:    f.server.URL + "/token",
+		UserInfoURL: f.server.URL,
+	})
+	verifier := f.authorize(t, p)
+
+	identity, err := p.Exchange(context.Background(), "good-code", verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "42", identity.Subject)
+	assert.Equal(t, "jane", identity.Name)
+	assert.Equal(t, "jane@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	f := newFakeProvider(t)
+	p := NewOIDC("acme", Config{
+		ClientID:    "client-1",
+		AuthURL:     f.server.URL + "/authorize",
+		TokenURL:    f.server.URL + "/token",
+		UserInfoURL: f.server.URL + "/userinfo",
+	})
+	f.authorize(t, p)
+
+	other, err := GenerateVerifier()
+	require.NoError(t, err)
+	_, err = p.Exchange(context.Background(), "good-code", other, "nonce-1")
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "invalid_grant"))
+}
+
+func newTestOIDC(f *fakeProvider) *OIDC {
+	return NewOIDC("acme", Config{
+		ClientID:    "client-1",
+		AuthURL:     f.server.URL + "/authorize",
+		TokenURL:    f.server.URL + "/token",
+		UserInfoURL: f.server.URL + "/userinfo",
+	})
+}
+
+func TestOIDCExchange_ChecksNonce(t *testing.T) {
+	f := newFakeProvider(t)
+	p := newTestOIDC(f)
+	verifier := f.authorize(t, p)
+	assert.Equal(t, "nonce-1", f.nonce)
+
+	_, err := p.Exchange(context.Background(), "good-code", verifier, "other-nonce")
+	assert.ErrorContains(t, err, "nonce")
+}
+
+func TestOIDCExchange_AcceptsStringEmailVerified(t *testing.T) {
+	f := newFakeProvider(t)
+	p := newTestOIDC(f)
+	for value, want := range map[string]bool{"true": true, "false": false} {
+		f.emailVerified = value
+		verifier := f.authorize(t, p)
+
+		identity, err := p.Exchange(context.Background(), "good-code", verifier, "nonce-1")
+		require.NoError(t, err)
+		assert.Equal(t, want, identity.EmailVerified)
+	}
+}
@@ -0,0 +1,243 @@
+package oauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Default endpoints, used when the configuration leaves them empty.
+const (
+	githubAuthURL  = "https://github.com/login/oauth/authorize"
+	githubTokenURL = "https://github.com/login/oauth/access_token"
+	githubAPIURL   = "https://api.github.com"
+
+	googleAuthURL     = "https://accounts.google.com/o/oauth2/v2/auth"
+	googleTokenURL    = "https://oauth2.googleapis.com/token"
+	googleUserInfoURL = "https://openidconnect.googleapis.com/v1/userinfo"
+)
+
+func withDefault(value string, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// GitHub signs users in with a GitHub OAuth app. GitHub isn't an OIDC
+// provider, so the identity comes from its REST API.
+type GitHub struct {
+	cfg Config
+}
+
+func NewGitHub(cfg Config) *GitHub {
+	cfg.AuthURL = withDefault(cfg.AuthURL, githubAuthURL)
+	cfg.TokenURL = withDefault(cfg.TokenURL, githubTokenURL)
+	cfg.UserInfoURL = withDefault(cfg.UserInfoURL, githubAPIURL)
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"read:user", "user:email"}
+	}
+	return &GitHub{cfg: cfg}
+}
+
+func (p *GitHub) Name() string {
+	return "github"
+}
+
+// AuthCodeURL ignores nonce, GitHub issues no ID token.
+func (p *GitHub) AuthCodeURL(state string, codeChallenge string, nonce string) string {
+	return p.cfg.authCodeURL(state, codeChallenge, "")
+}
+
+func (p *GitHub) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*Identity, error) {
+	token, err := p.cfg.exchangeCode(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	accessToken := token.AccessToken
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.cfg.getJSON(ctx, p.cfg.UserInfoURL+"/user", accessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("github: user has no id")
+	}
+
+	// The profile email is whatever the user made public, only the emails
+	// endpoint says whether an address is verified.
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.cfg.getJSON(ctx, p.cfg.UserInfoURL+"/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{Provider: p.Name(), Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			identity.Email = e.Email
+			identity.EmailVerified = true
+			break
+		}
+	}
+	return identity, nil
+}
+
+// OIDC signs users in with any OpenID Connect provider. The identity is read
+// from the userinfo endpoint with the access token we just redeemed. The ID
+// token comes straight from the token endpoint over TLS, so its signature isn't
+// verified, it is only checked to be meant for us and this login.
+type OIDC struct {
+	name string
+	cfg  Config
+}
+
+func NewOIDC(name string, cfg Config) *OIDC {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &OIDC{name: name, cfg: cfg}
+}
+
+// NewGoogle is an OIDC provider preconfigured with Google's endpoints.
+func NewGoogle(cfg Config) *OIDC {
+	cfg.AuthURL = withDefault(cfg.AuthURL, googleAuthURL)
+	cfg.TokenURL = withDefault(cfg.TokenURL, googleTokenURL)
+	cfg.UserInfoURL = withDefault(cfg.UserInfoURL, googleUserInfoURL)
+	return NewOIDC("google", cfg)
+}
+
+func (p *OIDC) Name() string {
+	return p.name
+}
+
+func (p *OIDC) AuthCodeURL(state string, codeChallenge string, nonce string) string {
+	return p.cfg.authCodeURL(state, codeChallenge, nonce)
+}
+
+func (p *OIDC) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*Identity, error) {
+	token, err := p.cfg.exchangeCode(ctx, code, codeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := parseIDToken(token.IDToken)
+	if err != nil {
+		return nil, err
+	}
+	if nonce == "" || idToken.Nonce != nonce {
+		return nil, errors.New("oidc: id token nonce doesn't match")
+	}
+	if !idToken.Audience.contains(p.cfg.ClientID) {
+		return nil, errors.New("oidc: id token is for another client")
+	}
+
+	var info struct {
+		Subject       string       `json:"sub"`
+		Email         string       `json:"email"`
+		EmailVerified flexibleBool `json:"email_verified"`
+		Name          string       `json:"name"`
+	}
+	if err := p.cfg.getJSON(ctx, p.cfg.UserInfoURL, token.AccessToken, &info); err != nil {
+		return nil, err
+	}
+	if info.Subject == "" {
+		return nil, errors.New("oidc: userinfo has no subject")
+	}
+	if info.Subject != idToken.Subject {
+		return nil, errors.New("oidc: userinfo and id token subjects differ")
+	}
+
+	return &Identity{
+		Provider:      p.name,
+		Subject:       info.Subject,
+		Email:         info.Email,
+		EmailVerified: bool(info.EmailVerified),
+		Name:          info.Name,
+	}, nil
+}
+
+type idTokenClaims struct {
+	Subject  string   `json:"sub"`
+	Audience audience `json:"aud"`
+	Nonce    string   `json:"nonce"`
+}
+
+// parseIDToken decodes the claims of an ID token without verifying its
+// signature.
+func parseIDToken(token string) (*idTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: token response has no valid id token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid id token: %w", err)
+	}
+	var claims idTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("oidc: invalid id token: %w", err)
+	}
+	return &claims, nil
+}
+
+// audience is the aud claim, which is a single string or a list.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// flexibleBool accepts booleans sent as JSON strings, which some providers do
+// for email_verified.
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = flexibleBool(value)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	value, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", s)
+	}
+	*b = flexibleBool(value)
+	return nil
+}
@@ -0,0 +1,59 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters keep the tests fast
+var testArgon2 = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHasher_Argon2id(t *testing.T) {
+	h := Hasher{Algorithm: Argon2id, Argon2: testArgon2}
+
+	hash, err := h.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	ok, err := h.Verify(hash, "correct horse")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = h.Verify(hash, "wrong horse")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.False(t, h.NeedsRehash(hash))
+	stronger := Hasher{Algorithm: Argon2id, Argon2: Argon2Params{Memory: 2048, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}}
+	assert.True(t, stronger.NeedsRehash(hash))
+}
+
+func TestHasher_VerifiesLegacyBcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("hunter22"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	h := Hasher{Algorithm: Argon2id, Argon2: testArgon2}
+	ok, err := h.Verify(string(legacy), "hunter22")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, h.NeedsRehash(string(legacy)))
+
+	sameCost := Hasher{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost}
+	assert.False(t, sameCost.NeedsRehash(string(legacy)))
+	higherCost := Hasher{Algorithm: Bcrypt, BcryptCost: bcrypt.MinCost + 1}
+	assert.True(t, higherCost.NeedsRehash(string(legacy)))
+}
+
+func TestHasher_RejectsUnknownHashes(t *testing.T) {
+	h := Hasher{Algorithm: Argon2id, Argon2: testArgon2}
+
+	_, err := h.Verify("plaintext", "plaintext")
+	assert.ErrorIs(t, err, ErrUnknownHash)
+	_, err = h.Verify("$argon2id$v=19$m=1024$broken", "x")
+	assert.Error(t, err)
+	assert.True(t, h.NeedsRehash(""))
+}
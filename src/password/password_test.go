@@ -0,0 +1,68 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rules(violations []Violation) []string {
+	var names []string
+	for _, v := range violations {
+		names = append(names, v.Rule)
+	}
+	return names
+}
+
+func TestScore(t *testing.T) {
+	weak := []string{"password", "12345678", "qwertyuiop", "aaaaaaaa", "Password1"}
+	for _, p := range weak {
+		assert.Less(t, Score(p), 2, p)
+	}
+	strong := []string{"correct horse battery staple", "Tr0ub4dor&3x", "vX9#qL2!mR7p"}
+	for _, p := range strong {
+		assert.GreaterOrEqual(t, Score(p), 3, p)
+	}
+}
+
+func TestScore_UsesUserInputs(t *testing.T) {
+	assert.Greater(t, Score("janedoe2024"), Score("janedoe2024", "janedoe"))
+}
+
+func TestCheck(t *testing.T) {
+	p := Policy{MinLength: 10, MaxLength: 72, MinCharClasses: 3, MinScore: 2}
+
+	violations, err := p.Check("V3ry-Unusual-Phrase", "jane@example.com")
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+
+	violations, _ = p.Check("short", "jane@example.com")
+	assert.ElementsMatch(t, []string{"length", "classes", "strength"}, rules(violations))
+
+	violations, _ = p.Check("Jane.Doe#2024!", "jane.doe@example.com")
+	assert.Contains(t, rules(violations), "email")
+}
+
+func TestBreachedList(t *testing.T) {
+	dir := t.TempDir()
+	// SHA-1 of "P@ssw0rd" is 21BD12DC183F740EE76F27B78EB39C8AD972A757
+	content := "0000000000000000000000000000000000A:1\n2DC183F740EE76F27B78EB39C8AD972A757:52579\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "21BD1.txt"), []byte(content), 0o600))
+
+	list := &BreachedList{Dir: dir}
+	breached, err := list.Contains("P@ssw0rd")
+	require.NoError(t, err)
+	assert.True(t, breached)
+
+	breached, err = list.Contains("something else entirely")
+	require.NoError(t, err)
+	assert.False(t, breached)
+
+	p := Policy{Breached: list}
+	violations, err := p.Check("P@ssw0rd", "")
+	require.NoError(t, err)
+	assert.Contains(t, rules(violations), "breached")
+}
@@ -0,0 +1,96 @@
+// Package password implements the password policy shared by registration,
+// password reset and password change.
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// MinCharClasses is how many of lowercase, uppercase, digits and symbols
+	// the password has to mix
+	MinCharClasses int
+	// MinScore is the lowest acceptable Score, from 0 to 4
+	MinScore int
+	// Breached rejects passwords from a known breach, nil disables the check
+	Breached *BreachedList
+}
+
+// Violation is one rule the password breaks.
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// Check returns every rule password breaks. email is the account's email,
+// which the password must not contain. Breach lookups that fail are skipped
+// and reported through err, so callers can decide to fail open.
+func (p Policy) Check(password string, email string) (violations []Violation, err error) {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, Violation{"length", fmt.Sprintf("must be at least %d characters", p.MinLength)})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, Violation{"length", fmt.Sprintf("must be at most %d characters", p.MaxLength)})
+	}
+	if classes := charClasses(password); classes < p.MinCharClasses {
+		violations = append(violations, Violation{"classes", fmt.Sprintf("must mix at least %d of lowercase letters, uppercase letters, digits and symbols", p.MinCharClasses)})
+	}
+	if containsEmail(password, email) {
+		violations = append(violations, Violation{"email", "must not contain your email address"})
+	}
+	if Score(password, emailParts(email)...) < p.MinScore {
+		violations = append(violations, Violation{"strength", "is too easy to guess"})
+	}
+	if p.Breached != nil {
+		breached, lookupErr := p.Breached.Contains(password)
+		if lookupErr != nil {
+			err = lookupErr
+		} else if breached {
+			violations = append(violations, Violation{"breached", "has appeared in a data breach, choose another one"})
+		}
+	}
+	return violations, err
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+// emailParts returns the pieces of an email someone might base a password on.
+func emailParts(email string) []string {
+	email = strings.ToLower(email)
+	local, domain, _ := strings.Cut(email, "@")
+	parts := []string{local}
+	if name, _, ok := strings.Cut(domain, "."); ok {
+		parts = append(parts, name)
+	}
+	return parts
+}
+
+func containsEmail(password string, email string) bool {
+	local, _, _ := strings.Cut(strings.ToLower(email), "@")
+	// Very short local parts would match by accident
+	if len(local) < 3 {
+		return false
+	}
+	return strings.Contains(strings.ToLower(password), local)
+}
@@ -0,0 +1,148 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// commonWords are the base words of the most used passwords. Matching them
+// case-insensitively anywhere in a password catches the usual variations.
+var commonWords = []string{
+	"password", "passwd", "pass", "qwerty", "letmein", "welcome", "admin",
+	"login", "dragon", "monkey", "master", "shadow", "sunshine", "princess",
+	"football", "baseball", "soccer", "hockey", "iloveyou", "trustno1",
+	"superman", "batman", "starwars", "whatever", "secret", "summer",
+	"winter", "spring", "autumn", "hello", "freedom", "charlie", "michael",
+	"jordan", "computer", "internet", "forgeit", "changeme", "default",
+	"test", "user", "guest", "root", "abc", "love",
+}
+
+// bruteforceCardinality is the guesses per character that matches no pattern.
+// Like zxcvbn we use 10 rather than the alphabet size, since people don't pick
+// characters uniformly at random.
+const bruteforceCardinality = 10
+
+// keyboardRows are walked forwards and backwards to spot sequences.
+var keyboardRows = []string{
+	"abcdefghijklmnopqrstuvwxyz",
+	"0123456789",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+}
+
+// Score estimates how hard a password is to guess, from 0 (trivial) to 4
+// (very hard), on the same scale as zxcvbn. It splits the password into the
+// patterns attackers try first (common words, the user's own details,
+// repeated characters and sequences) and brute forced characters, multiplies
+// the guesses each part needs and maps the total to a score.
+func Score(password string, userInputs ...string) int {
+	guesses := estimateGuesses(password, userInputs)
+	switch {
+	case guesses < 1e3:
+		return 0
+	case guesses < 1e6:
+		return 1
+	case guesses < 1e8:
+		return 2
+	case guesses < 1e10:
+		return 3
+	default:
+		return 4
+	}
+}
+
+func estimateGuesses(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	// Lowercased rune by rune so indexes line up with runes
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	guesses := 1.0
+
+	for i := 0; i < len(runes); {
+		n, g := longestPattern(runes, lower, i, userInputs)
+		if n == 0 {
+			n, g = 1, bruteforceCardinality
+		}
+		guesses *= g
+		i += n
+	}
+	return math.Max(guesses, 1)
+}
+
+// longestPattern finds the longest guessable pattern starting at i. It
+// returns its length and how many guesses it takes, or 0 when none matches.
+func longestPattern(runes []rune, lower []rune, i int, userInputs []string) (int, float64) {
+	best, bestGuesses := 0, 0.0
+	try := func(n int, g float64) {
+		if n > best {
+			best, bestGuesses = n, g
+		}
+	}
+
+	rest := string(lower[i:])
+	for _, input := range userInputs {
+		if len(input) >= 3 && strings.HasPrefix(rest, input) {
+			try(len([]rune(input)), 10*caseVariations(runes[i:i+len([]rune(input))]))
+		}
+	}
+	for rank, word := range commonWords {
+		if strings.HasPrefix(rest, word) {
+			try(len([]rune(word)), float64(100+rank)*caseVariations(runes[i:i+len([]rune(word))]))
+		}
+	}
+
+	if n := repeatLength(lower, i); n >= 3 {
+		try(n, float64(bruteforceCardinality*n))
+	}
+	if n := sequenceLength(lower, i); n >= 3 {
+		try(n, float64(50*n))
+	}
+	return best, bestGuesses
+}
+
+// caseVariations is the extra guesses needed when a word isn't all lowercase.
+func caseVariations(word []rune) float64 {
+	for _, r := range word {
+		if r >= 'A' && r <= 'Z' {
+			return 2
+		}
+	}
+	return 1
+}
+
+func repeatLength(lower []rune, i int) int {
+	n := 1
+	for i+n < len(lower) && lower[i+n] == lower[i] {
+		n++
+	}
+	return n
+}
+
+// sequenceLength measures a run like "abcd", "4321" or "qwer" starting at i.
+func sequenceLength(lower []rune, i int) int {
+	longest := 1
+	for _, row := range keyboardRows {
+		for _, step := range []int{1, -1} {
+			pos := strings.IndexRune(row, lower[i])
+			if pos < 0 {
+				continue
+			}
+			n := 1
+			for i+n < len(lower) {
+				next := pos + step*n
+				if next < 0 || next >= len(row) || rune(row[next]) != lower[i+n] {
+					break
+				}
+				n++
+			}
+			if n > longest {
+				longest = n
+			}
+		}
+	}
+	return longest
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore() (*MemoryStore, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	return store, &now
+}
+
+func TestMemoryStore_AllowsBurstThenRejects(t *testing.T) {
+	store, _ := newTestStore()
+	limit := Limit{Burst: 3, Period: time.Minute}
+
+	for i := 2; i >= 0; i-- {
+		res, err := store.Take(context.Background(), "ip:1", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, i, res.Remaining)
+	}
+
+	res, err := store.Take(context.Background(), "ip:1", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 3, res.Limit)
+	assert.Equal(t, 20*time.Second, res.RetryAfter)
+	assert.Equal(t, time.Minute, res.ResetAfter)
+}
+
+func TestMemoryStore_Refills(t *testing.T) {
+	store, now := newTestStore()
+	limit := Limit{Burst: 2, Period: time.Minute}
+
+	store.Take(context.Background(), "k", limit)
+	store.Take(context.Background(), "k", limit)
+	res, _ := store.Take(context.Background(), "k", limit)
+	assert.False(t, res.Allowed)
+
+	*now = now.Add(30 * time.Second)
+	res, _ = store.Take(context.Background(), "k", limit)
+	assert.True(t, res.Allowed)
+}
+
+func TestMemoryStore_KeysAreIndependent(t *testing.T) {
+	store, _ := newTestStore()
+	limit := Limit{Burst: 1, Period: time.Minute}
+
+	res, _ := store.Take(context.Background(), "a", limit)
+	assert.True(t, res.Allowed)
+	res, _ = store.Take(context.Background(), "b", limit)
+	assert.True(t, res.Allowed)
+}
+
+func TestMemoryStore_SweepsFullBuckets(t *testing.T) {
+	store, now := newTestStore()
+	limit := Limit{Burst: 1, Period: time.Second}
+
+	store.Take(context.Background(), "old", limit)
+	*now = now.Add(2 * time.Minute)
+	store.Take(context.Background(), "new", limit)
+
+	assert.NotContains(t, store.buckets, "old")
+	assert.Contains(t, store.buckets, "new")
+}
+
+func TestMemoryStore_SweepKeepsSlowBuckets(t *testing.T) {
+	store, now := newTestStore()
+	hourly := Limit{Burst: 3, Period: time.Hour}
+	perMinute := Limit{Burst: 30, Period: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		store.Take(context.Background(), "forgot", hourly)
+	}
+	*now = now.Add(61 * time.Second)
+	store.Take(context.Background(), "login", perMinute)
+
+	// The sweep ran on the fast route, but the hourly bucket has barely
+	// refilled and must not be reset
+	assert.Contains(t, store.buckets, "forgot")
+	res, _ := store.Take(context.Background(), "forgot", hourly)
+	assert.False(t, res.Allowed)
+}
@@ -0,0 +1,108 @@
+// Package rbac maps roles to the permissions they grant. Roles are stored on
+// the account and the resulting permissions are put in its access tokens, so
+// routes only need to check for a permission.
+package rbac
+
+import "sort"
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+const (
+	// Own profile
+	PermProfileRead  = "profile:read"
+	PermProfileWrite = "profile:write"
+
+	// Every profile
+	PermUsersRead  = "users:read"
+	PermUsersWrite = "users:write"
+
+	// Accounts, roles and OAuth clients
+	PermAccountsManage = "accounts:manage"
+	PermRolesAssign    = "roles:assign"
+	PermClientsManage  = "clients:manage"
+
+	// Audit log of every account
+	PermAuditRead = "audit:read"
+
+	// Acting as another account, for support
+	PermUsersImpersonate = "users:impersonate"
+
+	// Introspecting and revoking tokens issued to anyone, for machine
+	// clients such as API gateways
+	PermTokensIntrospect = "tokens:introspect"
+	PermTokensRevoke     = "tokens:revoke"
+)
+
+var rolePermissions = map[string][]string{
+	RoleUser: {PermProfileRead, PermProfileWrite},
+	RoleAdmin: {
+		PermProfileRead, PermProfileWrite,
+		PermUsersRead, PermUsersWrite,
+		PermAccountsManage, PermRolesAssign, PermClientsManage,
+		PermAuditRead, PermUsersImpersonate,
+		PermTokensIntrospect, PermTokensRevoke,
+	},
+}
+
+// DefaultRoles are given to accounts that were never assigned any.
+var DefaultRoles = []string{RoleUser}
+
+// IsRole reports whether role is known.
+func IsRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// IsPermission reports whether perm is granted by any role.
+func IsPermission(perm string) bool {
+	for _, perms := range rolePermissions {
+		if Has(perms, perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions returns the sorted union of the permissions of roles and the
+// extra permissions granted directly. Unknown roles grant nothing.
+func Permissions(roles []string, extra []string) []string {
+	set := make(map[string]bool)
+	for _, role := range roles {
+		for _, perm := range rolePermissions[role] {
+			set[perm] = true
+		}
+	}
+	for _, perm := range extra {
+		set[perm] = true
+	}
+
+	perms := make([]string, 0, len(set))
+	for perm := range set {
+		perms = append(perms, perm)
+	}
+	sort.Strings(perms)
+	return perms
+}
+
+// Exceeds reports whether perms holds anything role doesn't grant.
+func Exceeds(perms []string, role string) bool {
+	for _, perm := range perms {
+		if !Has(rolePermissions[role], perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// Has reports whether perm is in perms.
+func Has(perms []string, perm string) bool {
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
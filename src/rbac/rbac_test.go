@@ -0,0 +1,42 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissions_UserCanOnlyTouchOwnProfile(t *testing.T) {
+	perms := Permissions([]string{RoleUser}, nil)
+
+	assert.Equal(t, []string{PermProfileRead, PermProfileWrite}, perms)
+	assert.False(t, Has(perms, PermUsersWrite))
+	assert.False(t, Has(perms, PermRolesAssign))
+}
+
+func TestPermissions_AdminGetsEverything(t *testing.T) {
+	perms := Permissions([]string{RoleAdmin}, nil)
+
+	for _, perm := range []string{PermUsersRead, PermUsersWrite, PermAccountsManage, PermRolesAssign, PermClientsManage, PermAuditRead, PermUsersImpersonate, PermTokensIntrospect, PermTokensRevoke} {
+		assert.True(t, Has(perms, perm), perm)
+	}
+}
+
+func TestPermissions_MergesRolesAndExtraWithoutDuplicates(t *testing.T) {
+	perms := Permissions([]string{RoleUser, RoleUser, "unknown"}, []string{PermUsersRead, PermProfileRead})
+
+	assert.Equal(t, []string{PermProfileRead, PermProfileWrite, PermUsersRead}, perms)
+}
+
+func TestExceeds(t *testing.T) {
+	assert.False(t, Exceeds(Permissions([]string{RoleUser}, nil), RoleUser))
+	assert.True(t, Exceeds(Permissions([]string{RoleUser}, []string{PermAccountsManage}), RoleUser))
+	assert.False(t, Exceeds(Permissions([]string{RoleAdmin}, nil), RoleAdmin))
+}
+
+func TestIsRoleAndIsPermission(t *testing.T) {
+	assert.True(t, IsRole(RoleAdmin))
+	assert.False(t, IsRole("root"))
+	assert.True(t, IsPermission(PermClientsManage))
+	assert.False(t, IsPermission("everything"))
+}
@@ -9,12 +9,15 @@ import (
 
 // Common error codes
 const (
-	ErrCodeValidation    = "VALIDATION_ERROR"
-	ErrCodeNotFound      = "NOT_FOUND"
-	ErrCodeUnauthorized  = "UNAUTHORIZED"
-	ErrCodeInternalError = "INTERNAL_ERROR"
-	ErrCodeDuplicate     = "DUPLICATE_ENTRY"
-	ErrCodeBadRequest    = "BAD_REQUEST"
+	ErrCodeValidation      = "VALIDATION_ERROR"
+	ErrCodeNotFound        = "NOT_FOUND"
+	ErrCodeUnauthorized    = "UNAUTHORIZED"
+	ErrCodeForbidden       = "FORBIDDEN"
+	ErrCodeInternalError   = "INTERNAL_ERROR"
+	ErrCodeDuplicate       = "DUPLICATE_ENTRY"
+	ErrCodeConflict        = "CONFLICT"
+	ErrCodeBadRequest      = "BAD_REQUEST"
+	ErrCodeTooManyRequests = "TOO_MANY_REQUESTS"
 )
 
 type Response struct {
@@ -27,6 +30,15 @@ type Response struct {
 	RequestID   string      `json:"requestId"`
 }
 
+// RequestID returns the ID the requestid middleware gave the request, or a
+// fresh one when it isn't installed.
+func RequestID(c *fiber.Ctx) string {
+	if id, ok := c.Locals("requestid").(string); ok && id != "" {
+		return id
+	}
+	return uuid.New().String()
+}
+
 type ErrorInfo struct {
 	Code    string            `json:"code"`
 	Message string            `json:"message"`
@@ -40,7 +52,7 @@ func SendSuccessResponse(c *fiber.Ctx, status int, message string, data interfac
 		Message:   message,
 		Data:      data,
 		Timestamp: time.Now().UTC(),
-		RequestID: uuid.New().String(),
+		RequestID: RequestID(c),
 	}
 	return c.Status(status).JSON(response)
 }
@@ -50,7 +62,7 @@ func SendErrorResponse(c *fiber.Ctx, status int, code string, message string, de
 		Status:    status,
 		Success:   false,
 		Timestamp: time.Now().UTC(),
-		RequestID: uuid.New().String(),
+		RequestID: RequestID(c),
 		Message:  message,
 		Error: &ErrorInfo{
 			Code:    code,
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	"user-auth-profile-service/src/controllers"
+	"user-auth-profile-service/src/middleware"
+	"user-auth-profile-service/src/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func AdminRoute(app *fiber.App) {
+	admin := app.Group("/admin", middleware.AuthMiddleware, middleware.RequireRole(rbac.RoleAdmin))
+
+	// Accounts
+	manage := middleware.RequirePermission(rbac.PermAccountsManage)
+	admin.Get("/accounts", manage, controllers.SearchAccounts)
+	admin.Get("/accounts/:authId", manage, controllers.GetAccount)
+	admin.Get("/accounts/:authId/login-history", manage, controllers.GetLoginHistory)
+	admin.Post("/accounts/:authId/verify", manage, controllers.ForceVerifyAccount)
+	admin.Post("/accounts/:authId/resend-otp", manage, controllers.AdminResendOTP)
+	admin.Post("/accounts/:authId/password-reset", manage, controllers.AdminSendPasswordReset)
+	admin.Post("/accounts/:authId/disable", manage, controllers.DisableAccount)
+	admin.Post("/accounts/:authId/enable", manage, controllers.EnableAccount)
+	admin.Post("/accounts/:authId/revoke-sessions", manage, controllers.RevokeAccountSessions)
+	admin.Post("/accounts/:authId/unlock", manage, controllers.UnlockAccount)
+	admin.Put("/accounts/:authId/roles", middleware.RequirePermission(rbac.PermRolesAssign), controllers.AssignRoles)
+
+	// Support impersonation
+	admin.Post("/impersonate/:authId", middleware.RequirePermission(rbac.PermUsersImpersonate), controllers.Impersonate)
+
+	// Audit log
+	admin.Get("/audit-logs", middleware.RequirePermission(rbac.PermAuditRead), controllers.GetAuditLogs)
+
+	// Profiles
+	admin.Get("/users", middleware.RequirePermission(rbac.PermUsersRead), controllers.GetAllUsers)
+	admin.Delete("/users", middleware.RequirePermission(rbac.PermUsersWrite), controllers.DeleteAllUsers)
+}
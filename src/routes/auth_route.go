@@ -1,17 +1,103 @@
 package routes
 
 import (
+	"time"
+
 	"user-auth-profile-service/src/controllers"
 	"user-auth-profile-service/src/middleware"
+	"user-auth-profile-service/src/ratelimit"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// Rate limits for the endpoints that send emails or hash passwords. Routes
+// keyed by email also get a looser per IP limit, so one client can't spread
+// its requests across many emails.
+var (
+	registerLimit = middleware.RateLimit(middleware.RateLimitPolicy{
+		Name: "register", Limit: ratelimit.Limit{Burst: 5, Period: time.Hour}, Key: middleware.KeyByIP,
+	})
+	loginEmailLimit = middleware.RateLimit(middleware.RateLimitPolicy{
+		Name: "login", Limit: ratelimit.Limit{Burst: 10, Period: 15 * time.Minute}, Key: middleware.KeyByEmail,
+	})
+	authIPLimit = middleware.RateLimit(middleware.RateLimitPolicy{
+		Name: "auth-ip", Limit: ratelimit.Limit{Burst: 30, Period: time.Minute}, Key: middleware.KeyByIP,
+	})
+	forgotPasswordLimit = middleware.RateLimit(middleware.RateLimitPolicy{
+		Name: "forgot-password", Limit: ratelimit.Limit{Burst: 3, Period: time.Hour}, Key: middleware.KeyByEmail,
+	})
+	verifyOTPLimit = middleware.RateLimit(middleware.RateLimitPolicy{
+		Name: "verify-otp", Limit: ratelimit.Limit{Burst: 10, Period: 15 * time.Minute}, Key: middleware.KeyByEmail,
+	})
+	resendOTPLimit = middleware.RateLimit(middleware.RateLimitPolicy{
+		Name: "resend-otp", Limit: ratelimit.Limit{Burst: 3, Period: time.Hour}, Key: middleware.KeyByEmail,
+	})
+	magicLinkLimit = middleware.RateLimit(middleware.RateLimitPolicy{
+		Name: "magic-link", Limit: ratelimit.Limit{Burst: 3, Period: time.Hour}, Key: middleware.KeyByEmail,
+	})
+	changeEmailLimit = middleware.RateLimit(middleware.RateLimitPolicy{
+		Name: "change-email", Limit: ratelimit.Limit{Burst: 3, Period: time.Hour}, Key: middleware.KeyBySubject,
+	})
+	mfaVerifyLimit = middleware.RateLimit(middleware.RateLimitPolicy{
+		Name: "mfa-verify", Limit: ratelimit.Limit{Burst: 10, Period: 15 * time.Minute}, Key: middleware.KeyByIP,
+	})
+	emailIPLimit = middleware.RateLimit(middleware.RateLimitPolicy{
+		Name: "email-ip", Limit: ratelimit.Limit{Burst: 20, Period: time.Hour}, Key: middleware.KeyByIP,
+	})
+)
+
+// Routes that change credentials, sessions or the account itself are closed to
+// admins impersonating the account and to API keys, see
+// middleware.BlockImpersonation and middleware.BlockAPIKeys.
 func AuthRoute(app *fiber.App) {
-	app.Post("auth/register", controllers.Register)
-	app.Post("auth/login", controllers.Login)
-	app.Patch("auth/update-password",middleware.AuthMiddleware, controllers.UpdatePassword)
-	app.Post("auth/forgot-password", controllers.ForgotPassword)
-	app.Post("auth/reset-password", controllers.ResetPassword)
-	app.Post("auth/verify-otp",  controllers.VerifyOTP)
+	app.Post("auth/register", registerLimit, controllers.Register)
+	app.Post("auth/login", authIPLimit, loginEmailLimit, controllers.Login)
+	app.Post("auth/refresh", controllers.RefreshToken)
+	app.Post("auth/logout", middleware.AuthMiddleware, controllers.Logout)
+	app.Post("auth/logout-all", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.LogoutAll)
+	app.Get("auth/sessions", middleware.AuthMiddleware, middleware.BlockAPIKeys, controllers.ListSessions)
+	app.Delete("auth/sessions/:id", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.DeleteSession)
+
+	// API keys
+	app.Post("auth/api-keys", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.CreateAPIKey)
+	app.Get("auth/api-keys", middleware.AuthMiddleware, controllers.ListAPIKeys)
+	app.Delete("auth/api-keys/:id", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.RevokeAPIKey)
+	app.Patch("auth/update-password",middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.UpdatePassword)
+	app.Post("auth/forgot-password", emailIPLimit, forgotPasswordLimit, controllers.ForgotPassword)
+	app.Post("auth/reset-password", authIPLimit, controllers.ResetPassword)
+	app.Post("auth/verify-otp", authIPLimit, verifyOTPLimit, controllers.VerifyOTP)
+	app.Post("auth/resend-otp", emailIPLimit, resendOTPLimit, controllers.ResendOTP)
+
+	// Email address change
+	app.Post("auth/change-email", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, changeEmailLimit, controllers.RequestEmailChange)
+	app.Post("auth/change-email/confirm", controllers.ConfirmEmailChange)
+	app.Post("auth/change-email/revert", controllers.RevertEmailChange)
+
+	// Account deletion
+	app.Post("auth/delete-account", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.DeleteAccount)
+
+	// Security activity of the signed in account
+	app.Get("auth/security-activity", middleware.AuthMiddleware, controllers.GetSecurityActivity)
+
+	// Two-factor authentication
+	app.Post("auth/mfa/enroll", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.EnrollMFA)
+	app.Post("auth/mfa/confirm", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.ConfirmMFA)
+	app.Post("auth/mfa/disable", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.DisableMFA)
+	app.Post("auth/mfa/verify", authIPLimit, mfaVerifyLimit, controllers.VerifyMFA)
+
+	// Passwordless email login
+	app.Post("auth/magic-link", emailIPLimit, magicLinkLimit, controllers.RequestMagicLink)
+	app.Post("auth/magic-link/consume", controllers.ConsumeMagicLink)
+
+	// Social login
+	app.Get("auth/oauth/:provider", controllers.BeginOAuthLogin)
+	app.Get("auth/oauth/:provider/callback", controllers.OAuthCallback)
+
+	// Passkeys
+	app.Post("auth/webauthn/register/begin", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.BeginWebAuthnRegistration)
+	app.Post("auth/webauthn/register/finish", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.FinishWebAuthnRegistration)
+	app.Post("auth/webauthn/login/begin", controllers.BeginWebAuthnLogin)
+	app.Post("auth/webauthn/login/finish", controllers.FinishWebAuthnLogin)
+	app.Get("auth/webauthn/credentials", middleware.AuthMiddleware, controllers.ListWebAuthnCredentials)
+	app.Delete("auth/webauthn/credentials/:credentialId", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.DeleteWebAuthnCredential)
 }
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"user-auth-profile-service/src/controllers"
+	"user-auth-profile-service/src/middleware"
+	"user-auth-profile-service/src/rbac"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OIDCRoute registers the OpenID Connect provider endpoints used by the other
+// ForgeIt services to sign users in.
+func OIDCRoute(app *fiber.App) {
+	app.Get("/authorize", controllers.Authorize)
+	app.Post("/authorize", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.BlockAPIKeys, controllers.ApproveAuthorization)
+	app.Post("/token", controllers.Token)
+	app.Post("/oauth/token", controllers.Token)
+	app.Post("/oauth/introspect", controllers.Introspect)
+	app.Post("/oauth/revoke", controllers.Revoke)
+	app.Get("/userinfo", middleware.UserInfoMiddleware, controllers.UserInfo)
+	app.Post("/userinfo", middleware.UserInfoMiddleware, controllers.UserInfo)
+
+	// Client registration
+	app.Post("/oauth/clients", middleware.AuthMiddleware, middleware.RequirePermission(rbac.PermClientsManage), controllers.CreateOAuthClient)
+	app.Get("/oauth/clients", middleware.AuthMiddleware, middleware.RequirePermission(rbac.PermClientsManage), controllers.ListOAuthClients)
+	app.Delete("/oauth/clients/:clientId", middleware.AuthMiddleware, middleware.RequirePermission(rbac.PermClientsManage), controllers.DeleteOAuthClient)
+}
@@ -3,15 +3,25 @@ package routes
 import (
 	"user-auth-profile-service/src/controllers"
 	"user-auth-profile-service/src/middleware"
+	"user-auth-profile-service/src/rbac"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 func UserRoute(app *fiber.App) {
-	// Protected routes that require authentication
-	app.Post("/user", middleware.AuthMiddleware, controllers.CreateUser)
-	app.Get("/user/:userId", middleware.AuthMiddleware, controllers.GetAUser)
-	app.Put("/user/:userId", middleware.AuthMiddleware, controllers.EditAUser)
-	app.Delete("/user/:userId", middleware.AuthMiddleware, controllers.DeleteAUser)
-	app.Get("/users", middleware.AuthMiddleware, controllers.GetAllUsers)
+	// Protected routes that require authentication. Editing and deleting
+	// are limited to the caller's own profile unless they hold users:write.
+	// Admins impersonating an account can look around but not delete it.
+	app.Post("/user", middleware.AuthMiddleware, middleware.RequirePermission(rbac.PermProfileWrite), controllers.CreateUser)
+	app.Get("/user", middleware.AuthMiddleware, middleware.RequirePermission(rbac.PermUsersRead), controllers.GetUserByEmail)
+
+	// The caller's own profile, registered before /user/:userId so "me"
+	// isn't taken for an ID
+	app.Get("/user/me", middleware.AuthMiddleware, middleware.RequirePermission(rbac.PermProfileRead), controllers.GetMyProfile)
+	app.Put("/user/me", middleware.AuthMiddleware, middleware.RequirePermission(rbac.PermProfileWrite), controllers.EditMyProfile)
+	app.Delete("/user/me", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.RequirePermission(rbac.PermProfileWrite), controllers.DeleteMyProfile)
+
+	app.Get("/user/:userId", middleware.AuthMiddleware, middleware.RequirePermission(rbac.PermProfileRead), controllers.GetAUser)
+	app.Put("/user/:userId", middleware.AuthMiddleware, middleware.RequirePermission(rbac.PermProfileWrite), controllers.EditAUser)
+	app.Delete("/user/:userId", middleware.AuthMiddleware, middleware.BlockImpersonation, middleware.RequirePermission(rbac.PermProfileWrite), controllers.DeleteAUser)
 }
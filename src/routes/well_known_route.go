@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"user-auth-profile-service/src/controllers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func WellKnownRoute(app *fiber.App) {
+	app.Get("/.well-known/jwks.json", controllers.JWKS)
+	app.Get("/.well-known/openid-configuration", controllers.OpenIDConfiguration)
+}
@@ -0,0 +1,9 @@
+package structure
+
+type DisableAccountRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ImpersonateRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
@@ -0,0 +1,10 @@
+package structure
+
+import "time"
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,required"`
+	// Keys without an expiry work until they are revoked
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
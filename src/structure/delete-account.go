@@ -0,0 +1,5 @@
+package structure
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
@@ -0,0 +1,14 @@
+package structure
+
+import "time"
+
+const EventUserDeleted = "user.deleted"
+
+// UserEvent is published to the events queue so other ForgeIt services can
+// react to changes of an account.
+type UserEvent struct {
+	Event      string    `json:"event"`
+	AuthID     string    `json:"authId"`
+	Email      string    `json:"email"`
+	OccurredAt time.Time `json:"occurredAt"`
+}
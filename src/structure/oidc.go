@@ -0,0 +1,45 @@
+package structure
+
+type OAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required"`
+	RedirectURIs []string `json:"redirectUris" validate:"required_unless=Machine true,dive,url"`
+	Scopes       []string `json:"scopes"`
+	// Public clients get no secret and must use PKCE
+	Public bool `json:"public"`
+	// Machine clients use the client credentials grant, their scopes are
+	// permissions
+	Machine bool `json:"machine"`
+}
+
+// AuthorizeRequest carries the OAuth authorization request parameters, either
+// from the query string (GET /authorize) or the JSON body (POST /authorize).
+type AuthorizeRequest struct {
+	ResponseType        string `query:"response_type" json:"response_type"`
+	ClientID            string `query:"client_id" json:"client_id"`
+	RedirectURI         string `query:"redirect_uri" json:"redirect_uri"`
+	Scope               string `query:"scope" json:"scope"`
+	State               string `query:"state" json:"state"`
+	Nonce               string `query:"nonce" json:"nonce"`
+	CodeChallenge       string `query:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `query:"code_challenge_method" json:"code_challenge_method"`
+}
+
+// TokenRequest is the form posted to the token endpoint.
+type TokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+	Scope        string `form:"scope"`
+}
+
+// IntrospectionRequest is the form posted to the introspection and
+// revocation endpoints.
+type IntrospectionRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
@@ -0,0 +1,11 @@
+package structure
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// LogoutRequest optionally carries the refresh token of the session being
+// closed so that its whole token family can be revoked as well.
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
@@ -2,7 +2,11 @@ package structure
 
 type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=8"`
+	Password string `json:"password" validate:"required"`
+}
+
+type ResendOTPRequest struct {
+	Email string `json:"email" validate:"required,email"`
 }
 
 type VerifyOTPRequest struct {
@@ -12,19 +16,18 @@ type VerifyOTPRequest struct {
 
 // Add ResetRequest struct for ResetPassword functionality
 // Used in ResetPassword controller
-// Accepts email, token, password, confirmPassword
+// Accepts token, password, confirmPassword
 // All fields required
 //
 type ResetRequest struct {
-	Email           string `json:"email" validate:"required,email"`
 	Token           string `json:"token" validate:"required"`
-	Password        string `json:"password" validate:"required,min=8"`
-	ConfirmPassword string `json:"confirmPassword" validate:"required,min=8"`
+	Password        string `json:"password" validate:"required"`
+	ConfirmPassword string `json:"confirmPassword" validate:"required"`
 }
 
 // Add Request struct for UpdatePassword functionality
 type UpdatePasswordRequest struct {
-	Email           string `json:"email" validate:"required,email"`
+	Email           string `json:"email" validate:"omitempty,email"`
 	CurrentPassword string `json:"currentPassword" validate:"required"`
-	NewPassword     string `json:"newPassword" validate:"required,min=8"`
+	NewPassword     string `json:"newPassword" validate:"required"`
 }
\ No newline at end of file
@@ -0,0 +1,7 @@
+package structure
+
+type AssignRolesRequest struct {
+	Roles []string `json:"roles" validate:"required,min=1,dive,required"`
+	// Permissions granted on top of the roles
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
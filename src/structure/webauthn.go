@@ -0,0 +1,20 @@
+package structure
+
+import "user-auth-profile-service/src/webauthn"
+
+type WebAuthnRegisterFinishRequest struct {
+	SessionID  string                         `json:"sessionId" validate:"required"`
+	Name       string                         `json:"name" validate:"max=64"`
+	Credential *webauthn.RegistrationResponse `json:"credential" validate:"required"`
+}
+
+// WebAuthnLoginBeginRequest is accepted for older clients. The email is
+// ignored, logins always use a discoverable passkey.
+type WebAuthnLoginBeginRequest struct {
+	Email string `json:"email" validate:"omitempty,email"`
+}
+
+type WebAuthnLoginFinishRequest struct {
+	SessionID  string                      `json:"sessionId" validate:"required"`
+	Credential *webauthn.AssertionResponse `json:"credential" validate:"required"`
+}
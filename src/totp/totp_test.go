@@ -0,0 +1,58 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Seed from the RFC 6238 appendix B test vectors (SHA1)
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeAt_RFC6238Vectors(t *testing.T) {
+	// The RFC lists 8 digit codes, we use their last 6 digits
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, want := range vectors {
+		got, err := CodeAt(rfcSecret, Step(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, want, got, "time %d", unix)
+	}
+}
+
+func TestValidate_AllowsSkewAndRejectsReplay(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	previous, _ := CodeAt(rfcSecret, Step(now)-1)
+
+	step, ok := Validate(rfcSecret, previous, now, 1, 0)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now)-1, step)
+
+	_, ok = Validate(rfcSecret, previous, now, 1, step)
+	assert.False(t, ok, "a code must not be accepted twice")
+
+	_, ok = Validate(rfcSecret, previous, now, 0, 0)
+	assert.False(t, ok, "codes outside the skew window must be rejected")
+
+	_, ok = Validate(rfcSecret, "12345", now, 1, 0)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+
+	uri := URI("ForgeIt", "dev@example.com", secret)
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/ForgeIt:dev@example.com?"))
+	assert.Contains(t, uri, "secret="+secret)
+	assert.Contains(t, uri, "issuer=ForgeIt")
+}
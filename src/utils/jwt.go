@@ -2,50 +2,197 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
-	"os"
+	"strings"
 	"time"
 
 	"user-auth-profile-service/src/configs"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+var appConfig = configs.LoadEnv()
 
-func JWTSecretKey() string {
-    return os.Getenv("JWT_SECRET_KEY")
-}
-
-var SecretKey = []byte(JWTSecretKey())
+// Values of the token_use claim. AuthMiddleware only accepts access tokens,
+// every other kind of token is only good for the endpoint that consumes it.
+// Access tokens issued to OAuth clients for a user are TokenUseClientAccess,
+// only the userinfo endpoint accepts them. API keys aren't JWTs,
+// TokenUseAPIKey marks the claims AuthMiddleware builds for them.
+const (
+	TokenUseAccess       = "access"
+	TokenUseClientAccess = "client_access"
+	TokenUseAPIKey       = "api_key"
+	TokenUseMFAChallenge = "mfa_challenge"
+	TokenUseMagicLink    = "magic_link"
+	TokenUseID           = "id"
+)
 
 type JWTClaims struct {
 	Email string `json:"email"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(email string) (string, error) {
-	config := configs.LoadEnv()
-	
+// GenerateAccessToken issues a short-lived access token for the given account
+// and session. The account's roles and permissions are carried in the token,
+// see package rbac. It returns the signed token together with its expiry time.
+func GenerateAccessToken(authID string, email string, sessionID string, roles []string, permissions []string) (string, time.Time, error) {
+	claims, expiresAt := accessTokenClaims(authID, email)
+	claims["sid"] = sessionID
+	claims["roles"] = roles
+	claims["permissions"] = permissions
+
+	tokenString, err := SignClaims(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+// GenerateClientAccessToken issues an access token to an OAuth client acting
+// for the given account. The scope claim limits what the client may read, and
+// the token is no good for the service's own routes, see TokenUseClientAccess.
+func GenerateClientAccessToken(authID string, email string, clientID string, scope string) (string, time.Time, error) {
+	claims, expiresAt := accessTokenClaims(authID, email)
+	claims["token_use"] = TokenUseClientAccess
+	claims["client_id"] = clientID
+	claims["scope"] = scope
+
+	tokenString, err := SignClaims(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+// GenerateImpersonationToken issues an access token for the given account to
+// the admin actorID. The act claim (RFC 8693) names the admin. The token has
+// no session, so it can't be refreshed, and lives for ttl.
+func GenerateImpersonationToken(authID string, email string, roles []string, permissions []string, actorID string, actorEmail string, ttl time.Duration) (string, time.Time, error) {
+	claims, _ := accessTokenClaims(authID, email)
+	expiresAt := time.Now().Add(ttl)
+	claims["exp"] = expiresAt.Unix()
+	claims["roles"] = roles
+	claims["permissions"] = permissions
+	claims["act"] = map[string]interface{}{"sub": actorID, "email": actorEmail}
+
+	tokenString, err := SignClaims(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+// Actor returns the admin behind an impersonation token, see
+// GenerateImpersonationToken. ok is false for every other token.
+func Actor(claims jwt.MapClaims) (sub string, email string, ok bool) {
+	act, isMap := claims["act"].(map[string]interface{})
+	if !isMap {
+		return "", "", false
+	}
+	sub, _ = act["sub"].(string)
+	email, _ = act["email"].(string)
+	return sub, email, sub != ""
+}
+
+// GrantClientCredentials is the gty claim of access tokens issued to machine
+// clients. They act for the client itself, not for an account.
+const GrantClientCredentials = "client_credentials"
+
+// GenerateServiceAccessToken issues an access token to a machine client. The
+// granted scopes are its permissions.
+func GenerateServiceAccessToken(clientID string, scopes []string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(appConfig.AccessTokenTTL)
+
 	claims := jwt.MapClaims{
-		"email":  email,
-		"exp":   time.Now().Add(time.Hour * 24).Unix(),
-		"iss":   config.JWTIssuer,
+		"sub":         clientID,
+		"jti":         uuid.New().String(),
+		"client_id":   clientID,
+		"gty":         GrantClientCredentials,
+		"token_use":   TokenUseAccess,
+		"scope":       strings.Join(scopes, " "),
+		"permissions": scopes,
+		"iat":         now.Unix(),
+		"exp":         expiresAt.Unix(),
+		"iss":         appConfig.JWTIssuer,
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(config.JWTSecret))
+	tokenString, err := SignClaims(claims)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+func accessTokenClaims(authID string, email string) (jwt.MapClaims, time.Time) {
+	now := time.Now()
+	expiresAt := now.Add(appConfig.AccessTokenTTL)
+
+	return jwt.MapClaims{
+		"sub":       authID,
+		"jti":       uuid.New().String(),
+		"email":     email,
+		"token_use": TokenUseAccess,
+		"iat":       now.Unix(),
+		"exp":       expiresAt.Unix(),
+		"iss":       appConfig.JWTIssuer,
+	}, expiresAt
+}
+
+// ClaimStrings returns a claim holding a list of strings, such as roles or
+// permissions. Missing or malformed claims give an empty list.
+func ClaimStrings(claims jwt.MapClaims, name string) []string {
+	// Claims built in process rather than parsed from a JWT hold []string
+	if values, ok := claims[name].([]string); ok {
+		return values
+	}
+	values, _ := claims[name].([]interface{})
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			result = append(result, s)
+		}
 	}
+	return result
+}
 
-	return tokenString, nil
+// GenerateIDToken issues an OpenID Connect ID token for clientID. extra holds
+// the scope dependent user claims (email, name, ...).
+func GenerateIDToken(authID string, clientID string, nonce string, authTime time.Time, extra map[string]interface{}) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":       authID,
+		"aud":       clientID,
+		"token_use": TokenUseID,
+		"auth_time": authTime.Unix(),
+		"iat":       now.Unix(),
+		"exp":       now.Add(appConfig.AccessTokenTTL).Unix(),
+		"iss":       appConfig.JWTIssuer,
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+	return SignClaims(claims)
 }
 
 func ParseJWT(tokenStr string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return SecretKey, nil
-	})
+	token, err := jwt.Parse(tokenStr, verificationKey,
+		jwt.WithValidMethods([]string{
+			jwt.SigningMethodHS256.Alg(),
+			jwt.SigningMethodRS256.Alg(),
+			jwt.SigningMethodEdDSA.Alg(),
+		}))
 	if err != nil || !token.Valid {
 		return nil, errors.New("invalid token")
 	}
@@ -58,9 +205,68 @@ func ParseJWT(tokenStr string) (jwt.MapClaims, error) {
 	return claims, nil
 }
 
-func GenerateResetToken() string {
-	b := make([]byte, 8)
-	rand.Read(b)
-	return hex.EncodeToString(b)
+// GenerateMFAChallengeToken issues the interim token handed out by Login when
+// the account has two-factor authentication enabled. challengeID ties the token
+// to the challenge stored on the account, so it can be used only once.
+func GenerateMFAChallengeToken(authID string, challengeID string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":       authID,
+		"jti":       challengeID,
+		"token_use": TokenUseMFAChallenge,
+		"iat":       now.Unix(),
+		"exp":       now.Add(appConfig.MFAChallengeTTL).Unix(),
+		"iss":       appConfig.JWTIssuer,
+	}
+	return SignClaims(claims)
+}
+
+// GenerateMagicLinkToken signs the token embedded in a magic login link. The
+// token is bound to the email it was sent to, and linkID must match the link
+// stored on the account when it is consumed.
+func GenerateMagicLinkToken(authID string, email string, linkID string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub":       authID,
+		"jti":       linkID,
+		"email":     email,
+		"token_use": TokenUseMagicLink,
+		"iat":       now.Unix(),
+		"exp":       now.Add(appConfig.MagicLinkTTL).Unix(),
+		"iss":       appConfig.JWTIssuer,
+	}
+	return SignClaims(claims)
+}
+
+// ParseTypedJWT is ParseJWT for callers that expect specific kinds of token.
+func ParseTypedJWT(tokenStr string, tokenUses ...string) (jwt.MapClaims, error) {
+	claims, err := ParseJWT(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	use, _ := claims["token_use"].(string)
+	for _, tokenUse := range tokenUses {
+		if use == tokenUse {
+			return claims, nil
+		}
+	}
+	return nil, errors.New("unexpected token type")
+}
+
+// GenerateOpaqueToken returns a random, URL-safe token built from n bytes of
+// entropy. Opaque tokens are never stored as-is, see HashToken.
+func GenerateOpaqueToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
+// HashToken returns the hex encoded SHA-256 digest of an opaque token. High
+// entropy tokens don't need a slow hash, and a deterministic digest lets us
+// look them up directly.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
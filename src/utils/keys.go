@@ -0,0 +1,276 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key files in JWT_KEYS_DIR are named after their key ID:
+//
+//	<kid>.pem      private key, can sign and verify
+//	<kid>.pub.pem  public key only, verifies tokens signed before a rotation
+//
+// Rotating keys without downtime: add the new private key, publish it through
+// the JWKS endpoint for a while, switch JWT_SIGNING_KID to it, and once the old
+// tokens have expired replace the old private key with its public half or drop
+// it altogether.
+const (
+	privateKeySuffix = ".pem"
+	publicKeySuffix  = ".pub.pem"
+)
+
+type signingKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+type keyRing struct {
+	// active signs new tokens, nil when running with HS256
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+var (
+	ring     *keyRing
+	ringErr  error
+	ringOnce sync.Once
+)
+
+// InitSigningKeys loads the configured signing keys. It is called on startup so
+// that a broken key setup stops the service instead of failing every login.
+func InitSigningKeys() error {
+	_, err := getKeyRing()
+	return err
+}
+
+func getKeyRing() (*keyRing, error) {
+	ringOnce.Do(func() {
+		ring, ringErr = loadKeyRing(appConfig.JWTAlgorithm, appConfig.JWTKeysDir, appConfig.JWTSigningKeyID)
+	})
+	return ring, ringErr
+}
+
+func loadKeyRing(algorithm string, dir string, activeID string) (*keyRing, error) {
+	kr := &keyRing{keys: map[string]*signingKey{}}
+
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		if appConfig.JWTSecret == "" {
+			return nil, errors.New("JWT_SECRET is required for HS256")
+		}
+		return kr, nil
+	case jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg():
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", algorithm)
+	}
+
+	if dir == "" {
+		return nil, fmt.Errorf("JWT_KEYS_DIR is required for %s", algorithm)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWT_KEYS_DIR: %w", err)
+	}
+
+	var privateIDs []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, privateKeySuffix) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key %s: %w", name, err)
+		}
+
+		var key *signingKey
+		if strings.HasSuffix(name, publicKeySuffix) {
+			key, err = parsePublicKey(strings.TrimSuffix(name, publicKeySuffix), data)
+		} else {
+			key, err = parsePrivateKey(strings.TrimSuffix(name, privateKeySuffix), data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %s: %w", name, err)
+		}
+		if _, exists := kr.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		kr.keys[key.ID] = key
+		if key.Private != nil {
+			privateIDs = append(privateIDs, key.ID)
+		}
+	}
+
+	if activeID == "" {
+		if len(privateIDs) != 1 {
+			return nil, errors.New("JWT_SIGNING_KID is required unless JWT_KEYS_DIR holds exactly one private key")
+		}
+		activeID = privateIDs[0]
+	}
+	active, ok := kr.keys[activeID]
+	if !ok || active.Private == nil {
+		return nil, fmt.Errorf("no private key found for JWT_SIGNING_KID %q", activeID)
+	}
+	if active.Method.Alg() != algorithm {
+		return nil, fmt.Errorf("signing key %q is a %s key, JWT_ALGORITHM is %s", activeID, active.Method.Alg(), algorithm)
+	}
+	kr.active = active
+
+	return kr, nil
+}
+
+func parsePrivateKey(id string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		// Fall back to the "RSA PRIVATE KEY" format openssl used to default to
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return &signingKey{ID: id, Method: jwt.SigningMethodRS256, Private: key, Public: &key.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &signingKey{ID: id, Method: jwt.SigningMethodEdDSA, Private: key, Public: key.Public()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+}
+
+func parsePublicKey(id string, data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch key := parsed.(type) {
+	case *rsa.PublicKey:
+		return &signingKey{ID: id, Method: jwt.SigningMethodRS256, Public: key}, nil
+	case ed25519.PublicKey:
+		return &signingKey{ID: id, Method: jwt.SigningMethodEdDSA, Public: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", parsed)
+	}
+}
+
+// SignClaims signs claims with the active key, falling back to HS256 with
+// JWT_SECRET when no asymmetric key is configured.
+func SignClaims(claims jwt.MapClaims) (string, error) {
+	kr, err := getKeyRing()
+	if err != nil {
+		return "", err
+	}
+
+	if kr.active == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(appConfig.JWTSecret))
+	}
+
+	token := jwt.NewWithClaims(kr.active.Method, claims)
+	token.Header["kid"] = kr.active.ID
+	return token.SignedString(kr.active.Private)
+}
+
+// verificationKey is the jwt.Keyfunc used by ParseJWT. It selects the key by
+// the kid header and makes sure the token was signed with that key's algorithm.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	kr, err := getKeyRing()
+	if err != nil {
+		return nil, err
+	}
+
+	if kr.active == nil {
+		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(appConfig.JWTSecret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := kr.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.Public, nil
+}
+
+// SigningAlgorithm returns the JWS algorithm used for newly issued tokens.
+func SigningAlgorithm() string {
+	if kr, err := getKeyRing(); err == nil && kr.active != nil {
+		return kr.active.Method.Alg()
+	}
+	return jwt.SigningMethodHS256.Alg()
+}
+
+// JWKS returns the public halves of all configured keys as a JSON Web Key Set.
+// With HS256 there is nothing that may be published, so the set is empty.
+func JWKS() map[string]interface{} {
+	keys := []map[string]string{}
+
+	kr, err := getKeyRing()
+	if err == nil {
+		ids := make([]string, 0, len(kr.keys))
+		for id := range kr.keys {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			key := kr.keys[id]
+			jwk := map[string]string{
+				"kid": key.ID,
+				"use": "sig",
+				"alg": key.Method.Alg(),
+			}
+			switch pub := key.Public.(type) {
+			case *rsa.PublicKey:
+				jwk["kty"] = "RSA"
+				jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+				jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+			case ed25519.PublicKey:
+				jwk["kty"] = "OKP"
+				jwk["crv"] = "Ed25519"
+				jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+			}
+			keys = append(keys, jwk)
+		}
+	}
+
+	return map[string]interface{}{"keys": keys}
+}
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKey generates a key of the given algorithm and stores it in dir the way
+// an operator would, as <kid>.pem or, for publicOnly, as <kid>.pub.pem.
+func writeKey(t *testing.T, dir string, kid string, algorithm string, publicOnly bool) crypto.Signer {
+	var private crypto.Signer
+	switch algorithm {
+	case jwt.SigningMethodRS256.Alg():
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		private = key
+	case jwt.SigningMethodEdDSA.Alg():
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		private = key
+	default:
+		t.Fatalf("unsupported algorithm %s", algorithm)
+	}
+
+	var block *pem.Block
+	name := kid + privateKeySuffix
+	if publicOnly {
+		der, err := x509.MarshalPKIXPublicKey(private.Public())
+		require.NoError(t, err)
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+		name = kid + publicKeySuffix
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(private)
+		require.NoError(t, err)
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(block), 0600))
+
+	return private
+}
+
+// useKeyRing makes kr the key ring used by SignClaims and ParseJWT for the
+// rest of the test.
+func useKeyRing(t *testing.T, kr *keyRing) {
+	ringOnce.Do(func() {})
+	previous, previousErr := ring, ringErr
+	ring, ringErr = kr, nil
+	t.Cleanup(func() {
+		ring, ringErr = previous, previousErr
+	})
+}
+
+func loadTestKeyRing(t *testing.T, algorithm string, dir string, activeID string) {
+	kr, err := loadKeyRing(algorithm, dir, activeID)
+	require.NoError(t, err)
+	useKeyRing(t, kr)
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":       "65f1c0ffee0000000000abcd",
+		"token_use": TokenUseAccess,
+		"exp":       time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+// signWith signs claims with key under kid, bypassing the key ring.
+func signWith(t *testing.T, method jwt.SigningMethod, key interface{}, kid string) string {
+	token := jwt.NewWithClaims(method, testClaims())
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestSignAndParseRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()} {
+		t.Run(algorithm, func(t *testing.T) {
+			dir := t.TempDir()
+			writeKey(t, dir, "2024-01", algorithm, false)
+			loadTestKeyRing(t, algorithm, dir, "")
+
+			signed, err := SignClaims(testClaims())
+			require.NoError(t, err)
+
+			header, _, err := jwt.NewParser().ParseUnverified(signed, jwt.MapClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, algorithm, header.Method.Alg())
+			assert.Equal(t, "2024-01", header.Header["kid"])
+			assert.Equal(t, algorithm, SigningAlgorithm())
+
+			claims, err := ParseJWT(signed)
+			require.NoError(t, err)
+			assert.Equal(t, "65f1c0ffee0000000000abcd", claims["sub"])
+		})
+	}
+}
+
+func TestParseSelectsKeyByKid(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := writeKey(t, dir, "old", jwt.SigningMethodRS256.Alg(), false)
+	writeKey(t, dir, "new", jwt.SigningMethodRS256.Alg(), false)
+	loadTestKeyRing(t, jwt.SigningMethodRS256.Alg(), dir, "new")
+
+	// Tokens signed before the rotation stay valid
+	_, err := ParseJWT(signWith(t, jwt.SigningMethodRS256, oldKey, "old"))
+	assert.NoError(t, err)
+
+	signed, err := SignClaims(testClaims())
+	require.NoError(t, err)
+	header, _, err := jwt.NewParser().ParseUnverified(signed, jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "new", header.Header["kid"])
+
+	// A token claiming to be signed by the other key fails verification
+	_, err = ParseJWT(signWith(t, jwt.SigningMethodRS256, oldKey, "new"))
+	assert.Error(t, err)
+}
+
+func TestParseAcceptsRetiredPublicKey(t *testing.T) {
+	dir := t.TempDir()
+	retired := writeKey(t, dir, "retired", jwt.SigningMethodEdDSA.Alg(), true)
+	writeKey(t, dir, "current", jwt.SigningMethodEdDSA.Alg(), false)
+	loadTestKeyRing(t, jwt.SigningMethodEdDSA.Alg(), dir, "")
+
+	_, err := ParseJWT(signWith(t, jwt.SigningMethodEdDSA, retired, "retired"))
+	assert.NoError(t, err)
+}
+
+func TestParseRejectsUnknownKid(t *testing.T) {
+	dir := t.TempDir()
+	key := writeKey(t, dir, "current", jwt.SigningMethodRS256.Alg(), false)
+	loadTestKeyRing(t, jwt.SigningMethodRS256.Alg(), dir, "")
+
+	_, err := ParseJWT(signWith(t, jwt.SigningMethodRS256, key, "unknown"))
+	assert.Error(t, err)
+
+	_, err = ParseJWT(signWith(t, jwt.SigningMethodRS256, key, ""))
+	assert.Error(t, err)
+}
+
+func TestParseRejectsMismatchedAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "rsa", jwt.SigningMethodRS256.Alg(), false)
+	edKey := writeKey(t, dir, "ed", jwt.SigningMethodEdDSA.Alg(), true)
+	loadTestKeyRing(t, jwt.SigningMethodRS256.Alg(), dir, "rsa")
+
+	// An EdDSA token naming the RSA key
+	_, err := ParseJWT(signWith(t, jwt.SigningMethodEdDSA, edKey, "rsa"))
+	assert.Error(t, err)
+
+	// HS256 is only accepted when no asymmetric key is configured
+	_, err = ParseJWT(signWith(t, jwt.SigningMethodHS256, []byte("test-secret"), "rsa"))
+	assert.Error(t, err)
+	_, err = ParseJWT(signWith(t, jwt.SigningMethodHS256, []byte("test-secret"), ""))
+	assert.Error(t, err)
+}
+
+func TestParseRejectsAsymmetricTokensWithHS256(t *testing.T) {
+	useKeyRing(t, &keyRing{keys: map[string]*signingKey{}})
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, err = ParseJWT(signWith(t, jwt.SigningMethodEdDSA, key, "ed"))
+	assert.Error(t, err)
+	assert.Equal(t, jwt.SigningMethodHS256.Alg(), SigningAlgorithm())
+}
+
+func TestLoadKeyRingErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a", jwt.SigningMethodRS256.Alg(), false)
+	writeKey(t, dir, "b", jwt.SigningMethodRS256.Alg(), false)
+	writeKey(t, dir, "c", jwt.SigningMethodEdDSA.Alg(), true)
+
+	_, err := loadKeyRing(jwt.SigningMethodRS256.Alg(), dir, "")
+	assert.Error(t, err, "several private keys need JWT_SIGNING_KID")
+
+	_, err = loadKeyRing(jwt.SigningMethodRS256.Alg(), dir, "c")
+	assert.Error(t, err, "public keys can't sign")
+
+	_, err = loadKeyRing(jwt.SigningMethodEdDSA.Alg(), dir, "a")
+	assert.Error(t, err, "the signing key must match JWT_ALGORITHM")
+
+	_, err = loadKeyRing("ES256", dir, "a")
+	assert.Error(t, err)
+
+	_, err = loadKeyRing(jwt.SigningMethodRS256.Alg(), dir, "a")
+	assert.NoError(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey := writeKey(t, dir, "b-rsa", jwt.SigningMethodRS256.Alg(), false)
+	edKey := writeKey(t, dir, "a-ed", jwt.SigningMethodEdDSA.Alg(), true)
+	loadTestKeyRing(t, jwt.SigningMethodRS256.Alg(), dir, "b-rsa")
+
+	keys := JWKS()["keys"].([]map[string]string)
+	require.Len(t, keys, 2)
+
+	ed := keys[0]
+	assert.Equal(t, "a-ed", ed["kid"])
+	assert.Equal(t, "OKP", ed["kty"])
+	assert.Equal(t, "Ed25519", ed["crv"])
+	assert.Equal(t, "EdDSA", ed["alg"])
+	assert.Equal(t, "sig", ed["use"])
+	assert.Equal(t, []byte(edKey.Public().(ed25519.PublicKey)), mustDecode(t, ed["x"]))
+
+	rs := keys[1]
+	assert.Equal(t, "b-rsa", rs["kid"])
+	assert.Equal(t, "RSA", rs["kty"])
+	assert.Equal(t, "RS256", rs["alg"])
+	assert.Equal(t, "sig", rs["use"])
+	assert.Equal(t, "AQAB", rs["e"])
+	assert.Equal(t, rsaKey.Public().(*rsa.PublicKey).N.Bytes(), mustDecode(t, rs["n"]))
+	for _, key := range keys {
+		assert.NotContains(t, key, "d", "private key material must not be published")
+	}
+}
+
+func TestJWKSEmptyWithHS256(t *testing.T) {
+	useKeyRing(t, &keyRing{keys: map[string]*signingKey{}})
+
+	assert.Empty(t, JWKS()["keys"])
+}
+
+func mustDecode(t *testing.T, s string) []byte {
+	b, err := jwt.NewParser().DecodeSegment(s)
+	require.NoError(t, err)
+	return b
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-auth-profile-service/src/configs"
+	"user-auth-profile-service/src/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var revokedTokenCol = configs.GetCollection(configs.DB, "revoked_tokens")
+
+// RevokeAccessToken revokes a single access token until it would have expired
+// on its own.
+func RevokeAccessToken(ctx context.Context, jti string, authID primitive.ObjectID, expiresAt time.Time) error {
+	if jti == "" {
+		return errors.New("token has no jti")
+	}
+	_, err := revokedTokenCol.UpdateOne(ctx,
+		bson.M{"jti": jti},
+		bson.M{"$setOnInsert": models.RevokedToken{
+			JTI:       jti,
+			AuthID:    authID,
+			RevokedAt: time.Now(),
+			ExpiresAt: expiresAt,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// RevokeSession revokes every access token issued in the session sid. Tokens
+// of a session are only issued until its refresh tokens are revoked, so the
+// record can expire once the last of them has.
+func RevokeSession(ctx context.Context, sid string, authID primitive.ObjectID) error {
+	if sid == "" {
+		return errors.New("empty session id")
+	}
+	now := time.Now()
+	_, err := revokedTokenCol.UpdateOne(ctx,
+		bson.M{"sid": sid},
+		bson.M{"$setOnInsert": models.RevokedToken{
+			SessionID: sid,
+			AuthID:    authID,
+			RevokedAt: now,
+			ExpiresAt: now.Add(appConfig.AccessTokenTTL),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// RevokeAllAccessTokens revokes every access token issued to authID so far.
+// The record can expire once the longest-lived of those tokens has.
+func RevokeAllAccessTokens(ctx context.Context, authID primitive.ObjectID) error {
+	now := time.Now()
+	_, err := revokedTokenCol.UpdateOne(ctx,
+		bson.M{"authId": authID, "jti": bson.M{"$exists": false}, "sid": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{
+			"validAfter": now,
+			"revokedAt":  now,
+			"expiresAt":  now.Add(appConfig.AccessTokenTTL),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// IsAccessTokenRevoked reports whether the token described by claims was
+// revoked individually, with its session or by a logout from all devices.
+func IsAccessTokenRevoked(ctx context.Context, claims jwt.MapClaims) (bool, error) {
+	jti, _ := claims["jti"].(string)
+	conditions := []bson.M{{"jti": jti}}
+	if sid, _ := claims["sid"].(string); sid != "" {
+		conditions = append(conditions, bson.M{"sid": sid})
+	}
+
+	sub, _ := claims.GetSubject()
+	issuedAt, _ := claims.GetIssuedAt()
+	if authID, err := primitive.ObjectIDFromHex(sub); err == nil && issuedAt != nil {
+		// iat only has second precision, so a token issued in the same second
+		// as a logout-all is treated as revoked.
+		conditions = append(conditions, bson.M{
+			"authId":     authID,
+			"validAfter": bson.M{"$gte": issuedAt.Time},
+		})
+	}
+
+	err := revokedTokenCol.FindOne(ctx, bson.M{"$or": conditions}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"strings"
+
+	"user-auth-profile-service/src/models"
+	"user-auth-profile-service/src/rbac"
+)
+
+// AccountRoles returns the roles to put in user's tokens. Accounts listed in
+// ADMIN_EMAILS are always admins, so there is someone to assign roles.
+func AccountRoles(user models.Auth) []string {
+	roles := user.Roles
+	if len(roles) == 0 {
+		roles = rbac.DefaultRoles
+	}
+	for _, admin := range appConfig.AdminEmails {
+		if strings.EqualFold(user.Email, admin) && !rbac.Has(roles, rbac.RoleAdmin) {
+			return append(append([]string{}, roles...), rbac.RoleAdmin)
+		}
+	}
+	return roles
+}
@@ -5,13 +5,16 @@ import (
 
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/google/uuid"
 )
 
@@ -48,3 +51,26 @@ func UploadToS3(client *s3.Client, bucketName string, file io.Reader, originalFi
 	url := fmt.Sprintf("https://%s.s3.amazonaws.com/%s", bucketName, key)
 	return url, nil
 }
+
+// ErrNotInBucket is returned by DeleteFromS3 for URLs that don't point into
+// the bucket, such as links stored before uploads went through UploadToS3.
+var ErrNotInBucket = errors.New("object is not in the bucket")
+
+// DeleteFromS3 removes an object uploaded with UploadToS3, given its URL. An
+// object that no longer exists counts as deleted.
+func DeleteFromS3(client *s3.Client, bucketName string, objectURL string) error {
+	prefix := fmt.Sprintf("https://%s.s3.amazonaws.com/", bucketName)
+	if !strings.HasPrefix(objectURL, prefix) {
+		return fmt.Errorf("%w: %s", ErrNotInBucket, objectURL)
+	}
+
+	_, err := client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(strings.TrimPrefix(objectURL, prefix)),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,11 @@
+package utils
+
+import "go.mongodb.org/mongo-driver/mongo"
+
+// UseTestDB points the collections of this package at the test database, so
+// the tokens, accounts and API keys tests store there are found.
+func UseTestDB(db *mongo.Database) {
+	apiKeyCol = db.Collection("api_keys")
+	accountCol = db.Collection("auth")
+	revokedTokenCol = db.Collection("revoked_tokens")
+}
@@ -0,0 +1,449 @@
+// Package webauthn implements the relying party side of the WebAuthn
+// registration and authentication ceremonies for passkeys.
+//
+// Only what passwordless login needs is supported: "none" and self "packed"
+// attestation, and ES256, EdDSA and RS256 credentials. Attestation
+// certificates are not verified, we don't restrict which authenticators may be
+// used.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const challengeSize = 32
+
+// Authenticator data flags
+const (
+	flagUserPresent      = 0x01
+	flagUserVerified     = 0x04
+	flagBackupEligible   = 0x08
+	flagAttestedCredData = 0x40
+)
+
+// Config describes the relying party.
+type Config struct {
+	// RPID is the domain credentials are scoped to, e.g. "forgeit.dev"
+	RPID   string
+	RPName string
+	// Origins lists the origins the browser may report, e.g. "https://forgeit.dev"
+	Origins []string
+	Timeout time.Duration
+	// RequireUserVerification rejects ceremonies without a PIN or biometric
+	RequireUserVerification bool
+}
+
+// User is the account a credential is registered for. ID is the opaque user
+// handle stored on the authenticator and must not contain personal data.
+type User struct {
+	ID          []byte
+	Name        string
+	DisplayName string
+}
+
+// Credential is what the relying party stores about a registered authenticator.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte // COSE encoded
+	SignCount uint32
+	AAGUID    []byte
+	// BackupEligible is set for synced passkeys
+	BackupEligible bool
+}
+
+// URLEncodedBytes marshals to unpadded base64url, which is how WebAuthn
+// binary fields travel in JSON.
+type URLEncodedBytes []byte
+
+func (b URLEncodedBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *URLEncodedBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := decodeBase64URL(s)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// decodeBase64URL accepts base64url with or without padding.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UserEntity struct {
+	ID          URLEncodedBytes `json:"id"`
+	Name        string          `json:"name"`
+	DisplayName string          `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+type CredentialDescriptor struct {
+	Type       string          `json:"type"`
+	ID         URLEncodedBytes `json:"id"`
+	Transports []string        `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey string `json:"residentKey"`
+	// RequireResidentKey is the Level 1 spelling of ResidentKey, for older
+	// browsers
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// CreationOptions is passed to navigator.credentials.create() as publicKey.
+type CreationOptions struct {
+	Challenge              URLEncodedBytes        `json:"challenge"`
+	RP                     RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials,omitempty"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is passed to navigator.credentials.get() as publicKey.
+type RequestOptions struct {
+	Challenge        URLEncodedBytes        `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout,omitempty"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials,omitempty"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationResponse is the JSON form of the PublicKeyCredential returned by
+// navigator.credentials.create().
+type RegistrationResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AttestationObject URLEncodedBytes `json:"attestationObject"`
+		Transports        []string        `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the JSON form of the PublicKeyCredential returned by
+// navigator.credentials.get().
+type AssertionResponse struct {
+	ID       string          `json:"id"`
+	RawID    URLEncodedBytes `json:"rawId"`
+	Type     string          `json:"type"`
+	Response struct {
+		ClientDataJSON    URLEncodedBytes `json:"clientDataJSON"`
+		AuthenticatorData URLEncodedBytes `json:"authenticatorData"`
+		Signature         URLEncodedBytes `json:"signature"`
+		UserHandle        URLEncodedBytes `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+type collectedClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// NewChallenge returns a random ceremony challenge.
+func NewChallenge() ([]byte, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (cfg Config) userVerification() string {
+	if cfg.RequireUserVerification {
+		return "required"
+	}
+	return "preferred"
+}
+
+// BeginRegistration builds the options for registering a new credential for
+// user. Existing credentials are excluded so an authenticator isn't registered
+// twice.
+func (cfg Config) BeginRegistration(user User, existing []Credential) (*CreationOptions, error) {
+	challenge, err := NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	options := &CreationOptions{
+		Challenge: challenge,
+		RP:        RelyingPartyEntity{ID: cfg.RPID, Name: cfg.RPName},
+		User:      UserEntity{ID: user.ID, Name: user.Name, DisplayName: user.DisplayName},
+		PubKeyCredParams: []CredentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout: cfg.Timeout.Milliseconds(),
+		// Logins don't name the account, so passkeys must be discoverable
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   cfg.userVerification(),
+		},
+		Attestation: "none",
+	}
+	for _, cred := range existing {
+		options.ExcludeCredentials = append(options.ExcludeCredentials, CredentialDescriptor{Type: "public-key", ID: cred.ID})
+	}
+	return options, nil
+}
+
+// FinishRegistration verifies the authenticator's response to the challenge
+// from BeginRegistration and returns the credential to store.
+func (cfg Config) FinishRegistration(challenge []byte, resp *RegistrationResponse) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, errors.New("unexpected credential type")
+	}
+	if err := cfg.verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := decodeCBOR(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid attestation object: %w", err)
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, errors.New("invalid attestation object")
+	}
+	format, _ := attestation["fmt"].(string)
+	rawAuthData, _ := attestation["authData"].([]byte)
+	statement, _ := attestation["attStmt"].(map[interface{}]interface{})
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.verifyAuthenticatorData(authData); err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttestedCredData == 0 {
+		return nil, errors.New("no attested credential data")
+	}
+	if len(resp.RawID) > 0 && !bytes.Equal(resp.RawID, authData.credentialID) {
+		return nil, errors.New("credential id mismatch")
+	}
+
+	key, err := parseCOSEKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return nil, errors.New("unexpected attestation statement")
+		}
+	case "packed":
+		// Self attestation: signed by the credential key itself. Statements
+		// with an x5c chain would require trusting vendor certificates.
+		if _, hasChain := statement["x5c"]; hasChain {
+			return nil, errors.New("full attestation is not supported")
+		}
+		alg, _ := statement["alg"].(int64)
+		sig, _ := statement["sig"].([]byte)
+		if alg != key.alg {
+			return nil, errors.New("attestation algorithm mismatch")
+		}
+		clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+		if err := key.verify(append(append([]byte{}, rawAuthData...), clientDataHash[:]...), sig); err != nil {
+			return nil, fmt.Errorf("attestation: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported attestation format %q", format)
+	}
+
+	return &Credential{
+		ID:             authData.credentialID,
+		PublicKey:      authData.publicKey,
+		SignCount:      authData.signCount,
+		AAGUID:         authData.aaguid,
+		BackupEligible: authData.flags&flagBackupEligible != 0,
+	}, nil
+}
+
+// BeginLogin builds the options for an assertion. With no allowed credentials
+// the browser offers any discoverable credential (passkey) for the RP.
+func (cfg Config) BeginLogin(allowed []Credential) (*RequestOptions, error) {
+	challenge, err := NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	options := &RequestOptions{
+		Challenge:        challenge,
+		RPID:             cfg.RPID,
+		Timeout:          cfg.Timeout.Milliseconds(),
+		UserVerification: cfg.userVerification(),
+	}
+	for _, cred := range allowed {
+		options.AllowCredentials = append(options.AllowCredentials, CredentialDescriptor{Type: "public-key", ID: cred.ID})
+	}
+	return options, nil
+}
+
+// Assertion is the outcome of a verified login ceremony.
+type Assertion struct {
+	// SignCount is the new signature counter to store
+	SignCount uint32
+	// UserVerified is set when the authenticator checked a PIN or biometric,
+	// not just the user's presence
+	UserVerified bool
+}
+
+// FinishLogin verifies an assertion made with cred against the challenge from
+// BeginLogin.
+func (cfg Config) FinishLogin(challenge []byte, resp *AssertionResponse, cred Credential) (Assertion, error) {
+	if resp.Type != "public-key" {
+		return Assertion{}, errors.New("unexpected credential type")
+	}
+	if !bytes.Equal(resp.RawID, cred.ID) {
+		return Assertion{}, errors.New("credential id mismatch")
+	}
+	if err := cfg.verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge); err != nil {
+		return Assertion{}, err
+	}
+
+	authData, err := parseAuthenticatorData(resp.Response.AuthenticatorData)
+	if err != nil {
+		return Assertion{}, err
+	}
+	if err := cfg.verifyAuthenticatorData(authData); err != nil {
+		return Assertion{}, err
+	}
+
+	key, err := parseCOSEKey(cred.PublicKey)
+	if err != nil {
+		return Assertion{}, err
+	}
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	signed := append(append([]byte{}, resp.Response.AuthenticatorData...), clientDataHash[:]...)
+	if err := key.verify(signed, resp.Response.Signature); err != nil {
+		return Assertion{}, err
+	}
+
+	// A counter that doesn't move forward means the authenticator may have
+	// been cloned. Authenticators that don't count always report zero.
+	if (authData.signCount != 0 || cred.SignCount != 0) && authData.signCount <= cred.SignCount {
+		return Assertion{}, errors.New("signature counter did not increase, authenticator may be cloned")
+	}
+
+	return Assertion{
+		SignCount:    authData.signCount,
+		UserVerified: authData.flags&flagUserVerified != 0,
+	}, nil
+}
+
+func (cfg Config) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var clientData collectedClientData
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return errors.New("invalid client data")
+	}
+	if clientData.Type != ceremony {
+		return fmt.Errorf("unexpected client data type %q", clientData.Type)
+	}
+
+	got, err := decodeBase64URL(clientData.Challenge)
+	if err != nil || len(challenge) == 0 || !bytes.Equal(got, challenge) {
+		return errors.New("challenge mismatch")
+	}
+
+	for _, origin := range cfg.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q is not allowed", clientData.Origin)
+}
+
+func (cfg Config) verifyAuthenticatorData(authData *authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(cfg.RPID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return errors.New("relying party id mismatch")
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return errors.New("user presence is required")
+	}
+	if cfg.RequireUserVerification && authData.flags&flagUserVerified == 0 {
+		return errors.New("user verification is required")
+	}
+	return nil
+}
+
+// parseAuthenticatorData decodes the binary authenticator data structure:
+// rpIdHash (32) | flags (1) | signCount (4) | [attested credential data]
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data too short")
+	}
+
+	authData := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	if authData.flags&flagAttestedCredData == 0 {
+		return authData, nil
+	}
+
+	// aaguid (16) | credentialIdLength (2) | credentialId | COSE public key
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, errors.New("attested credential data too short")
+	}
+	authData.aaguid = rest[:16]
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, errors.New("invalid credential id")
+	}
+	authData.credentialID = rest[:idLen]
+	rest = rest[idLen:]
+
+	_, remaining, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid credential public key: %w", err)
+	}
+	authData.publicKey = rest[:len(rest)-len(remaining)]
+	return authData, nil
+}
@@ -0,0 +1,237 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = Config{
+	RPID:    "forgeit.test",
+	RPName:  "ForgeIt",
+	Origins: []string{"https://forgeit.test"},
+	Timeout: time.Minute,
+}
+
+// softwareAuthenticator emulates a platform authenticator holding a single
+// ES256 credential, enough to drive both ceremonies end to end.
+type softwareAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	counter      uint32
+	// presenceOnly emulates a security key without a PIN
+	presenceOnly bool
+}
+
+func newSoftwareAuthenticator(t *testing.T) *softwareAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softwareAuthenticator{key: key, credentialID: id}
+}
+
+func (a *softwareAuthenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	return encodeCBOR(map[int64]interface{}{
+		coseKeyType: coseKtyEC2,
+		coseKeyAlg:  AlgES256,
+		coseCurve:   coseCrvP256,
+		coseX:       x,
+		coseY:       y,
+	})
+}
+
+func (a *softwareAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.counter)
+	if attested {
+		data = append(data, make([]byte, 16)...) // zero AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+func clientDataJSON(ceremony string, challenge []byte, origin string) []byte {
+	data, _ := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    origin,
+	})
+	return data
+}
+
+func (a *softwareAuthenticator) create(options *CreationOptions, origin string) *RegistrationResponse {
+	resp := &RegistrationResponse{ID: base64.RawURLEncoding.EncodeToString(a.credentialID), RawID: a.credentialID, Type: "public-key"}
+	resp.Response.ClientDataJSON = clientDataJSON("webauthn.create", options.Challenge, origin)
+	resp.Response.AttestationObject = encodeCBOR(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(options.RP.ID, flagUserPresent|flagUserVerified|flagAttestedCredData, true),
+	})
+	return resp
+}
+
+func (a *softwareAuthenticator) get(options *RequestOptions, origin string) *AssertionResponse {
+	a.counter++
+	resp := &AssertionResponse{ID: base64.RawURLEncoding.EncodeToString(a.credentialID), RawID: a.credentialID, Type: "public-key"}
+	resp.Response.ClientDataJSON = clientDataJSON("webauthn.get", options.Challenge, origin)
+	flags := byte(flagUserPresent | flagUserVerified)
+	if a.presenceOnly {
+		flags = flagUserPresent
+	}
+	resp.Response.AuthenticatorData = a.authData(options.RPID, flags, false)
+
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, resp.Response.AuthenticatorData...), clientDataHash[:]...))
+	sig, _ := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	resp.Response.Signature = sig
+	return resp
+}
+
+// encodeCBOR is a minimal deterministic encoder for the values used above.
+func encodeCBOR(v interface{}) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 256:
+			return []byte{major<<5 | 24, byte(n)}
+		default:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		}
+	}
+	switch value := v.(type) {
+	case int64:
+		if value < 0 {
+			return head(1, uint64(-1-value))
+		}
+		return head(0, uint64(value))
+	case []byte:
+		return append(head(2, uint64(len(value))), value...)
+	case string:
+		return append(head(3, uint64(len(value))), value...)
+	case map[int64]interface{}:
+		keys := make([]int64, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		out := head(5, uint64(len(value)))
+		for _, k := range keys {
+			out = append(out, encodeCBOR(k)...)
+			out = append(out, encodeCBOR(value[k])...)
+		}
+		return out
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := head(5, uint64(len(value)))
+		for _, k := range keys {
+			out = append(out, encodeCBOR(k)...)
+			out = append(out, encodeCBOR(value[k])...)
+		}
+		return out
+	}
+	panic("unsupported value")
+}
+
+func registerCredential(t *testing.T, authenticator *softwareAuthenticator) *Credential {
+	options, err := testConfig.BeginRegistration(User{ID: []byte("user-1"), Name: "dev@forgeit.test"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "required", options.AuthenticatorSelection.ResidentKey)
+
+	cred, err := testConfig.FinishRegistration(options.Challenge, authenticator.create(options, "https://forgeit.test"))
+	require.NoError(t, err)
+	return cred
+}
+
+func TestRegistrationAndLogin(t *testing.T) {
+	authenticator := newSoftwareAuthenticator(t)
+	cred := registerCredential(t, authenticator)
+	assert.Equal(t, authenticator.credentialID, cred.ID)
+
+	options, err := testConfig.BeginLogin([]Credential{*cred})
+	require.NoError(t, err)
+	assert.Len(t, options.AllowCredentials, 1)
+
+	assertion, err := testConfig.FinishLogin(options.Challenge, authenticator.get(options, "https://forgeit.test"), *cred)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), assertion.SignCount)
+	assert.True(t, assertion.UserVerified)
+}
+
+func TestLogin_ReportsPresenceOnlyAssertions(t *testing.T) {
+	authenticator := newSoftwareAuthenticator(t)
+	cred := registerCredential(t, authenticator)
+	authenticator.presenceOnly = true
+
+	options, err := testConfig.BeginLogin(nil)
+	require.NoError(t, err)
+	assertion, err := testConfig.FinishLogin(options.Challenge, authenticator.get(options, "https://forgeit.test"), *cred)
+	require.NoError(t, err)
+	assert.False(t, assertion.UserVerified)
+
+	strict := testConfig
+	strict.RequireUserVerification = true
+	_, err = strict.FinishLogin(options.Challenge, authenticator.get(options, "https://forgeit.test"), *cred)
+	assert.ErrorContains(t, err, "user verification")
+}
+
+func TestRegistration_RejectsWrongOriginAndChallenge(t *testing.T) {
+	authenticator := newSoftwareAuthenticator(t)
+	options, err := testConfig.BeginRegistration(User{ID: []byte("user-1"), Name: "dev@forgeit.test"}, nil)
+	require.NoError(t, err)
+
+	_, err = testConfig.FinishRegistration(options.Challenge, authenticator.create(options, "https://evil.test"))
+	assert.ErrorContains(t, err, "origin")
+
+	other, _ := NewChallenge()
+	_, err = testConfig.FinishRegistration(other, authenticator.create(options, "https://forgeit.test"))
+	assert.ErrorContains(t, err, "challenge")
+}
+
+func TestLogin_RejectsBadSignatureAndReplayedCounter(t *testing.T) {
+	authenticator := newSoftwareAuthenticator(t)
+	cred := registerCredential(t, authenticator)
+
+	options, err := testConfig.BeginLogin(nil)
+	require.NoError(t, err)
+
+	resp := authenticator.get(options, "https://forgeit.test")
+	resp.Response.Signature[len(resp.Response.Signature)-1] ^= 0xff
+	_, err = testConfig.FinishLogin(options.Challenge, resp, *cred)
+	assert.Error(t, err)
+
+	// The authenticator is ahead of the stored counter, so pretend a clone
+	// with the same counter value signs next.
+	cred.SignCount = authenticator.counter + 1
+	_, err = testConfig.FinishLogin(options.Challenge, authenticator.get(options, "https://forgeit.test"), *cred)
+	assert.ErrorContains(t, err, "cloned")
+}
+
+func TestDecodeCBOR_RejectsTruncatedInput(t *testing.T) {
+	encoded := encodeCBOR(map[string]interface{}{"fmt": "none"})
+	_, _, err := decodeCBOR(encoded[:len(encoded)-1])
+	assert.Error(t, err)
+}